@@ -0,0 +1,84 @@
+package link
+
+import (
+	"context"
+	"github.com/lectio/resource"
+	"github.com/spf13/afero"
+	"golang.org/x/xerrors"
+	"net/url"
+	"sync/atomic"
+)
+
+// ErrDownloadBudgetExceeded is returned when writing an attachment would exceed the cumulative download budget
+var ErrDownloadBudgetExceeded = xerrors.New("attachment download budget exceeded")
+
+// DownloadBudget is a resource.FileAttachmentCreator which caps the total bytes written across all attachment
+// downloads. Pass it to NewFactory in place of the FileAttachmentCreator it wraps; it's safe for parallel downloads.
+// A download which exceeds the budget is reported as a LECTIOLINK-016-DOWNLOADBUDGETEXCEEDED Issue on the link.
+type DownloadBudget struct {
+	Creator  resource.FileAttachmentCreator
+	MaxBytes int64
+
+	usedBytes int64 // only accessed atomically
+}
+
+// NewDownloadBudget wraps the given creator with a budget of maxBytes across all attachments it creates
+func NewDownloadBudget(creator resource.FileAttachmentCreator, maxBytes int64) *DownloadBudget {
+	return &DownloadBudget{Creator: creator, MaxBytes: maxBytes}
+}
+
+// CreateFile satisfies resource.FileAttachmentCreator, failing fast once the budget is exhausted
+func (b *DownloadBudget) CreateFile(ctx context.Context, url *url.URL, t resource.Type) (afero.Fs, afero.File, error) {
+	if b.Remaining() <= 0 {
+		return nil, nil, ErrDownloadBudgetExceeded
+	}
+
+	fs, file, err := b.Creator.CreateFile(ctx, url, t)
+	if err != nil {
+		return fs, file, err
+	}
+	return fs, &budgetedFile{File: file, budget: b}, nil
+}
+
+// AutoAssignExtension satisfies resource.FileAttachmentCreator by delegating to the wrapped creator
+func (b *DownloadBudget) AutoAssignExtension(ctx context.Context, url *url.URL, t resource.Type) bool {
+	return b.Creator.AutoAssignExtension(ctx, url, t)
+}
+
+// Remaining returns the number of bytes which may still be written before the budget is exceeded
+func (b *DownloadBudget) Remaining() int64 {
+	return b.MaxBytes - atomic.LoadInt64(&b.usedBytes)
+}
+
+// reserve claims n bytes of the budget, returning false (and claiming nothing) if that would exceed it
+func (b *DownloadBudget) reserve(n int) bool {
+	if atomic.AddInt64(&b.usedBytes, int64(n)) > b.MaxBytes {
+		atomic.AddInt64(&b.usedBytes, -int64(n))
+		return false
+	}
+	return true
+}
+
+// budgetedFile counts every byte written to an attachment against its DownloadBudget
+type budgetedFile struct {
+	afero.File
+	budget *DownloadBudget
+}
+
+func (f *budgetedFile) Write(p []byte) (int, error) {
+	if !f.budget.reserve(len(p)) {
+		return 0, ErrDownloadBudgetExceeded
+	}
+	return f.File.Write(p)
+}
+
+func (f *budgetedFile) WriteAt(p []byte, off int64) (int, error) {
+	if !f.budget.reserve(len(p)) {
+		return 0, ErrDownloadBudgetExceeded
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *budgetedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
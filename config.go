@@ -0,0 +1,146 @@
+package link
+
+import (
+	"encoding/json"
+	"golang.org/x/xerrors"
+	"regexp"
+	"time"
+)
+
+// FactoryConfig is the JSON-friendly form of a DefaultFactory's rules and flags; regular expressions are stored
+// as their pattern strings. Policies, clients and other options passed to NewFactory are not part of it.
+type FactoryConfig struct {
+	IgnoreURLsRegExprs            []string            `json:"ignoreURLsRegExprs"`
+	RemoveParamsFromURLsRegEx     []string            `json:"removeParamsFromURLsRegEx"`
+	RemoveParamValuesRegEx        []string            `json:"removeParamValuesRegEx"`
+	UnwrapURLsRules               []URLWrapperPattern `json:"unwrapURLsRules"`
+	AllowedSchemes                []string            `json:"allowedSchemes"`
+	EnableCleaning                bool                `json:"enableCleaning"`
+	SemicolonSeparatesQueryParams bool                `json:"semicolonSeparatesQueryParams"`
+	CleanFragmentParams           bool                `json:"cleanFragmentParams"`
+	AllowFileScheme               bool                `json:"allowFileScheme"`
+	SkipTLSInfo                   bool                `json:"skipTLSInfo"`
+	WarnOnHostChange              bool                `json:"warnOnHostChange"`
+	WarnTLSExpiryWithin           time.Duration       `json:"warnTLSExpiryWithin"`
+	MaxConnsPerHost               int                 `json:"maxConnsPerHost"`
+	MaxIdleConnsPerHost           int                 `json:"maxIdleConnsPerHost"`
+	AllowRedirectsWithoutLocation bool                `json:"allowRedirectsWithoutLocation"`
+	ReportAllIgnoreRules          bool                `json:"reportAllIgnoreRules"`
+	RecordRedirectHops            bool                `json:"recordRedirectHops"`
+	AcceptHeader                  string              `json:"acceptHeader"`
+}
+
+// URLWrapperPattern is the JSON-friendly form of a URLWrapperRule
+type URLWrapperPattern struct {
+	Pattern    string `json:"pattern"`
+	QueryParam string `json:"queryParam"`
+}
+
+// Config returns the factory's current rules and flags
+func (f *DefaultFactory) Config() FactoryConfig {
+	config := FactoryConfig{
+		IgnoreURLsRegExprs:            regexPatterns(f.IgnoreURLsRegExprs),
+		RemoveParamsFromURLsRegEx:     regexPatterns(f.RemoveParamsFromURLsRegEx),
+		RemoveParamValuesRegEx:        regexPatterns(f.RemoveParamValuesRegEx),
+		AllowedSchemes:                f.AllowedSchemes,
+		EnableCleaning:                f.EnableCleaning,
+		SemicolonSeparatesQueryParams: f.SemicolonSeparatesQueryParams,
+		CleanFragmentParams:           f.CleanFragmentParams,
+		AllowFileScheme:               f.AllowFileScheme,
+		SkipTLSInfo:                   f.SkipTLSInfo,
+		WarnOnHostChange:              f.WarnOnHostChange,
+		WarnTLSExpiryWithin:           f.WarnTLSExpiryWithin,
+		MaxConnsPerHost:               f.MaxConnsPerHost,
+		MaxIdleConnsPerHost:           f.MaxIdleConnsPerHost,
+		AllowRedirectsWithoutLocation: f.AllowRedirectsWithoutLocation,
+		ReportAllIgnoreRules:          f.ReportAllIgnoreRules,
+		RecordRedirectHops:            f.RecordRedirectHops,
+		AcceptHeader:                  f.AcceptHeader,
+	}
+	for _, rule := range f.UnwrapURLsRules {
+		config.UnwrapURLsRules = append(config.UnwrapURLsRules, URLWrapperPattern{rule.Pattern.String(), rule.QueryParam})
+	}
+	return config
+}
+
+// ApplyConfig compiles the config's patterns and, if they're all valid, replaces the factory's rules and flags.
+// MaxConnsPerHost and MaxIdleConnsPerHost only take effect for factories created afterwards (see HostConnectionLimits).
+func (f *DefaultFactory) ApplyConfig(config FactoryConfig) error {
+	ignoreRegExprs, err := compileRegexPatterns("ignoreURLsRegExprs", config.IgnoreURLsRegExprs)
+	if err != nil {
+		return err
+	}
+	removeParamsRegEx, err := compileRegexPatterns("removeParamsFromURLsRegEx", config.RemoveParamsFromURLsRegEx)
+	if err != nil {
+		return err
+	}
+	removeParamValuesRegEx, err := compileRegexPatterns("removeParamValuesRegEx", config.RemoveParamValuesRegEx)
+	if err != nil {
+		return err
+	}
+	var unwrapRules []URLWrapperRule
+	for _, rule := range config.UnwrapURLsRules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return xerrors.Errorf("Invalid unwrapURLsRules pattern %q: %w", rule.Pattern, err)
+		}
+		unwrapRules = append(unwrapRules, URLWrapperRule{pattern, rule.QueryParam})
+	}
+
+	f.IgnoreURLsRegExprs = ignoreRegExprs
+	f.RemoveParamsFromURLsRegEx = removeParamsRegEx
+	f.RemoveParamValuesRegEx = removeParamValuesRegEx
+	f.UnwrapURLsRules = unwrapRules
+	f.AllowedSchemes = config.AllowedSchemes
+	f.EnableCleaning = config.EnableCleaning
+	f.SemicolonSeparatesQueryParams = config.SemicolonSeparatesQueryParams
+	f.CleanFragmentParams = config.CleanFragmentParams
+	f.AllowFileScheme = config.AllowFileScheme
+	f.SkipTLSInfo = config.SkipTLSInfo
+	f.WarnOnHostChange = config.WarnOnHostChange
+	f.WarnTLSExpiryWithin = config.WarnTLSExpiryWithin
+	f.MaxConnsPerHost = config.MaxConnsPerHost
+	f.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	f.AllowRedirectsWithoutLocation = config.AllowRedirectsWithoutLocation
+	f.ReportAllIgnoreRules = config.ReportAllIgnoreRules
+	f.RecordRedirectHops = config.RecordRedirectHops
+	f.AcceptHeader = config.AcceptHeader
+	return nil
+}
+
+// MarshalJSON encodes the factory's Config
+func (f *DefaultFactory) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Config())
+}
+
+// UnmarshalJSON applies a Config encoded by MarshalJSON; fields missing from data keep their current values, so
+// unmarshal into a factory created by NewFactory
+func (f *DefaultFactory) UnmarshalJSON(data []byte) error {
+	config := f.Config()
+	if err := json.Unmarshal(data, &config); err != nil {
+		return xerrors.Errorf("Unable to decode factory config: %w", err)
+	}
+	return f.ApplyConfig(config)
+}
+
+// regexPatterns returns the pattern strings of the given regular expressions
+func regexPatterns(regExprs []*regexp.Regexp) []string {
+	var patterns []string
+	for _, regEx := range regExprs {
+		patterns = append(patterns, regEx.String())
+	}
+	return patterns
+}
+
+// compileRegexPatterns compiles each pattern, reporting the first invalid one with the name of its config field
+func compileRegexPatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	var regExprs []*regexp.Regexp
+	for _, pattern := range patterns {
+		regEx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, xerrors.Errorf("Invalid %s pattern %q: %w", field, pattern, err)
+		}
+		regExprs = append(regExprs, regEx)
+	}
+	return regExprs, nil
+}
@@ -1,10 +1,22 @@
 package link
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"golang.org/x/xerrors"
+	"net"
 )
 
+// ErrLinkIgnored is returned by TraverseLinkStrict, wrapped with the ignore reason, when a link was ignored
+var ErrLinkIgnored = xerrors.New("link ignored")
+
+// ErrNoAttachment is returned by TraversedLink.OpenAttachment when no attachment was downloaded for the link
+var ErrNoAttachment = xerrors.New("no attachment was downloaded")
+
+// ErrSchemeNotAllowed is wrapped by the error returned when a link redirects to a URL whose scheme may not be traversed
+var ErrSchemeNotAllowed = xerrors.New("scheme not allowed")
+
 // InvalidHTTPRespStatusCodeError is used as Error.Code when the traversed URL returns a non-200 status code
 type InvalidHTTPRespStatusCodeError struct {
 	Message        string
@@ -53,3 +65,30 @@ func (e URLStructureInvalidError) Format(f fmt.State, c rune) {
 func (e URLStructureInvalidError) Error() string {
 	return fmt.Sprint(e)
 }
+
+// invalidURLCode classifies the error returned while fetching a URL so callers can tell network failures apart
+func invalidURLCode(err error) (string, string) {
+	if xerrors.Is(err, ErrSchemeNotAllowed) {
+		return "LECTIOLINK-008-SCHEMENOTALLOWED", "Redirected to a URL whose scheme is not allowed"
+	}
+
+	var dnsErr *net.DNSError
+	if xerrors.As(err, &dnsErr) {
+		return "LECTIOLINK-003-DNSERROR", "Unable to resolve host"
+	}
+
+	var netErr net.Error
+	if xerrors.As(err, &netErr) && netErr.Timeout() {
+		return "LECTIOLINK-004-TIMEOUT", "Timed out fetching URL"
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	if xerrors.As(err, &unknownAuthorityErr) || xerrors.As(err, &certInvalidErr) || xerrors.As(err, &hostnameErr) || xerrors.As(err, &recordHeaderErr) {
+		return "LECTIOLINK-005-TLSERROR", "TLS handshake or certificate verification failed"
+	}
+
+	return "LECTIOLINK-001-INVALIDURL", "Unable to construct URL"
+}
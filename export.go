@@ -0,0 +1,84 @@
+package link
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// LinkExportVersion is the version of the LinkExport wire format; it's incremented whenever a field is renamed,
+// removed or changes meaning (adding fields doesn't change the version)
+const LinkExportVersion = 1
+
+// LinkExport is the stable, flat wire format for a TraversedLink. Unlike TraversedLink, whose JSON follows its
+// internal structure, LinkExport only uses strings, numbers and booleans so that downstream consumers aren't
+// affected by refactoring.
+type LinkExport struct {
+	Version       int     `json:"version"`       // always LinkExportVersion
+	TraversedOn   string  `json:"traversedOn"`   // RFC 3339 timestamp, empty if unknown
+	OrigURL       string  `json:"origURL"`       // the URL text as it was given
+	ResolvedURL   string  `json:"resolvedURL"`   // the URL after HTTP redirects
+	CleanedURL    string  `json:"cleanedURL"`    // the URL after query params were cleaned, empty if nothing was cleaned
+	FinalURL      string  `json:"finalURL"`      // the URL to use for the link
+	Valid         bool    `json:"valid"`         // false if the URL couldn't be fetched
+	InvalidCode   string  `json:"invalidCode"`   // LECTIOLINK-NNN-* code explaining why the URL isn't valid
+	Ignored       bool    `json:"ignored"`       // true if the link was ignored or is invalid
+	Reason        string  `json:"reason"`        // human-readable reason the link is invalid or ignored
+	Status        int     `json:"status"`        // HTTP status code, 0 if there was no HTTP response
+	RedirectCount int     `json:"redirectCount"` // number of HTTP redirects followed
+	Title         string  `json:"title"`         // see TraversedLink.DisplayTitle
+	Summary       string  `json:"summary"`       // see TraversedLink.Summary
+	MediaType     string  `json:"mediaType"`     // media type of the Content-Type without params, empty if unknown
+	HTML          bool    `json:"html"`          // true if the content was HTML which was inspected for metadata
+	Downloaded    bool    `json:"downloaded"`    // true if the content was downloaded as an attachment
+	Issues        []Issue `json:"issues"`        // non-fatal observations, never null
+}
+
+// Export returns the link in the stable LinkExport wire format
+func (l *TraversedLink) Export() LinkExport {
+	export := LinkExport{
+		Version:       LinkExportVersion,
+		OrigURL:       l.OrigURLText,
+		ResolvedURL:   exportURL(l.ResolvedURL),
+		CleanedURL:    exportURL(l.CleanedURL),
+		FinalURL:      exportURL(l.FinalizedURL),
+		Valid:         l.IsURLValid,
+		InvalidCode:   l.InvalidURLCode,
+		Ignored:       l.IsURLIgnored,
+		Reason:        l.IgnoreReason,
+		Status:        l.HTTPStatusCode,
+		RedirectCount: l.HTTPRedirectCount,
+		Title:         l.DisplayTitle(),
+		Summary:       l.Summary(),
+		HTML:          l.IsHTML(),
+		Downloaded:    l.WasDownloaded(),
+		Issues:        append([]Issue{}, l.Issues...),
+	}
+	if l.Content != nil && l.Content.Type() != nil {
+		export.MediaType = l.Content.Type().MediaType()
+	}
+	if !l.TraversedOn.IsZero() {
+		export.TraversedOn = l.TraversedOn.Format(time.RFC3339)
+	}
+	return export
+}
+
+// MarshalJSON encodes the export, filling in the version and an empty issues list if they weren't set
+func (e LinkExport) MarshalJSON() ([]byte, error) {
+	type wireFormat LinkExport // avoids recursing into MarshalJSON
+	if e.Version == 0 {
+		e.Version = LinkExportVersion
+	}
+	if e.Issues == nil {
+		e.Issues = []Issue{}
+	}
+	return json.Marshal(wireFormat(e))
+}
+
+// exportURL renders an optional URL as a string
+func exportURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
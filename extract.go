@@ -0,0 +1,52 @@
+package link
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// URLExtractor finds the URLs in source content such as tweets, markdown or plain text emails
+type URLExtractor interface {
+	Extract(text string) []string
+}
+
+// RegExURLExtractor is a URLExtractor which finds URLs matching a regular expression
+type RegExURLExtractor struct {
+	RegEx *regexp.Regexp
+}
+
+var defaultURLExtractorRegEx = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// NewURLExtractor returns the default URLExtractor, which finds http and https URLs in plain text
+func NewURLExtractor() *RegExURLExtractor {
+	return &RegExURLExtractor{RegEx: defaultURLExtractorRegEx}
+}
+
+// Extract returns the URLs matched in text, with trailing sentence punctuation removed
+func (e *RegExURLExtractor) Extract(text string) []string {
+	urls := e.RegEx.FindAllString(text, -1)
+	for i, urlText := range urls {
+		urls[i] = strings.TrimRight(urlText, ".,;:!?)]}")
+	}
+	return urls
+}
+
+// TraverseText extracts the URLs in text and traverses each of them in order. A URLExtractor may be passed in
+// options to control how URLs are found; otherwise the default (NewURLExtractor) is used.
+func (f *DefaultFactory) TraverseText(ctx context.Context, text string, options ...interface{}) []TraversalResult {
+	var extractor URLExtractor = NewURLExtractor()
+	for _, option := range options {
+		if instance, ok := option.(URLExtractor); ok {
+			extractor = instance
+		}
+	}
+
+	urls := extractor.Extract(text)
+	results := make([]TraversalResult, len(urls))
+	for i, urlText := range urls {
+		traversable, link, err := f.TraverseLink(ctx, urlText, options...)
+		results[i] = TraversalResult{urlText, traversable, link, err}
+	}
+	return results
+}
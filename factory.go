@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"github.com/lectio/resource"
 	"golang.org/x/xerrors"
+	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -15,6 +18,7 @@ type Link interface {
 	OriginalURL() string
 	FinalURL() (*url.URL, error)
 	Traversable(warn func(code, message string)) bool
+	DisplayTitle() string
 }
 
 // Factory is a lifecycle manager for URL-based resources
@@ -26,45 +30,338 @@ type Factory interface {
 func NewFactory(options ...interface{}) *DefaultFactory {
 	f := &DefaultFactory{}
 
-	f.ResourceFactory = resource.NewFactory(options...)
+	f.AllowedSchemes = []string{"http", "https"}
+	f.AcceptHeader = DefaultAcceptHeader
 
 	f.IgnoreLinkPolicy = f // we implemented a default version
 	f.IgnoreURLsRegExprs = []*regexp.Regexp{regexp.MustCompile(`^https://twitter.com/(.*?)/status/(.*)$`), regexp.MustCompile(`https://t.co`)}
-	f.RemoveParamsFromURLsRegEx = []*regexp.Regexp{regexp.MustCompile(`^utm_`)}
+	f.RemoveParamsFromURLsRegEx = DefaultTrackingParamRegexes()
+	f.UnwrapURLsRules = DefaultURLWrapperRules()
+	f.EnableCleaning = true
 
 	f.CleanLinkQueryParamsPolicy = f         // we implemented a default version
+	f.CleanLinkQueryParamValuesPolicy = f    // we implemented a default version
 	f.FollowRedirectsInHTMLContentPolicy = f // we implemented a default version
+	f.HTTPClientProvider = httpClientProvider{&http.Client{Timeout: DefaultHTTPClientTimeout}}
 
 	f.initOptions(options...)
 
+	// the resource factory performs the actual HTTP requests so it must use the same client and pass on the
+	// traversal's context (it's appended last so it takes precedence over any provider or preparer in options)
+	f.httpClient = f.newHTTPClient()
+	f.ResourceFactory = resource.NewFactory(append(options, newResourceFetcher(f.httpClient, options))...)
+
 	return f
 }
 
+// DefaultTrackingParamRegexes returns the rules NewFactory uses to clean common tracking params from URLs:
+// Google Analytics campaign params (utm_*), Facebook (fbclid), Google Ads (gclid, dclid), Microsoft Ads (msclkid),
+// Yandex (yclid), Mailchimp (mc_cid, mc_eid), Instagram (igshid) and Google Analytics linker params (_ga, _gl).
+// Replace or append to DefaultFactory.RemoveParamsFromURLsRegEx to override them.
+func DefaultTrackingParamRegexes() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`^utm_`),
+		regexp.MustCompile(`^(fbclid|gclid|dclid|msclkid|yclid)$`),
+		regexp.MustCompile(`^mc_(cid|eid)$`),
+		regexp.MustCompile(`^igshid$`),
+		regexp.MustCompile(`^_(ga|gl)$`),
+	}
+}
+
+// URLWrapperRule recognizes a redirector or reader-proxy URL (e.g. https://www.google.com/url?q=...) which wraps
+// the real URL in one of its query params
+type URLWrapperRule struct {
+	Pattern    *regexp.Regexp `json:"pattern"`
+	QueryParam string         `json:"queryParam"`
+}
+
+// DefaultURLWrapperRules returns the rules NewFactory uses to unwrap Google's /url?q= (and /url?url=) redirector and
+// the 12ft.io reader proxy. Replace or append to DefaultFactory.UnwrapURLsRules to override them.
+func DefaultURLWrapperRules() []URLWrapperRule {
+	google := regexp.MustCompile(`^https?://(www\.)?google\.[a-z.]+/url\?`)
+	return []URLWrapperRule{
+		{google, "q"},
+		{google, "url"},
+		{regexp.MustCompile(`^https?://12ft\.io/proxy\?`), "q"},
+	}
+}
+
 // IgnoreLinkPolicy indicates whether a given URL should be ignored or harvested
 type IgnoreLinkPolicy interface {
 	IgnoreLink(context.Context, *url.URL) (bool, string)
 }
 
+// IgnoreLinkRulePolicy is an optional extension of IgnoreLinkPolicy which also returns the rule that matched, so
+// ignored links can be categorized by rule; TraverseLink records the rule as TraversedLink.IgnoreRule
+type IgnoreLinkRulePolicy interface {
+	IgnoreLinkRule(context.Context, *url.URL) (bool, string, *regexp.Regexp)
+}
+
 // CleanLinkQueryParamsPolicy indicates whether a specific URL parameter should be "cleaned" (removed)
 type CleanLinkQueryParamsPolicy interface {
 	CleanLinkParams(ctx context.Context, url *url.URL) bool
 	RemoveQueryParamFromLinkURL(ctx context.Context, url *url.URL, paramName string) (bool, string)
 }
 
+// CleanLinkQueryParamValuesPolicy indicates whether a specific URL parameter should be "cleaned" (removed) based on its value
+type CleanLinkQueryParamValuesPolicy interface {
+	RemoveQueryParamValueFromLinkURL(ctx context.Context, url *url.URL, paramName string, paramValue string) (bool, string)
+}
+
 // FollowRedirectsInHTMLContentPolicy indicates whether we want to perform any destination actions
 type FollowRedirectsInHTMLContentPolicy interface {
 	FollowRedirectsInHTMLContent(context.Context, *url.URL) bool
 }
 
+// FollowHTMLRedirects is a FollowRedirectsInHTMLContentPolicy which always answers the same way; pass it as a
+// TraverseLink option to turn following <meta http-equiv='refresh'> redirects on or off for a single call
+type FollowHTMLRedirects bool
+
+// FollowRedirectsInHTMLContent returns the wrapped value for every URL
+func (follow FollowHTMLRedirects) FollowRedirectsInHTMLContent(context.Context, *url.URL) bool {
+	return bool(follow)
+}
+
+// DefaultAcceptHeader is the Accept header sent by default; it steers servers towards the canonical HTML version of a page
+const DefaultAcceptHeader = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// Accept may be passed as a TraverseLink option to send a different Accept header for that call only (e.g. to ask for JSON)
+type Accept string
+
+// traversalPolicies holds the policies in effect for a single TraverseLink call
+type traversalPolicies struct {
+	IgnoreLinkPolicy                   IgnoreLinkPolicy
+	CleanLinkQueryParamsPolicy         CleanLinkQueryParamsPolicy
+	CleanLinkQueryParamValuesPolicy    CleanLinkQueryParamValuesPolicy
+	FollowRedirectsInHTMLContentPolicy FollowRedirectsInHTMLContentPolicy
+	AcceptHeader                       string
+}
+
+type traversalPoliciesContextKey struct{}
+
+// withPolicyOverrides returns a context carrying any policies passed as TraverseLink options
+func withPolicyOverrides(ctx context.Context, options []interface{}) context.Context {
+	overrides := new(traversalPolicies)
+	found := false
+	for _, option := range options {
+		if instance, ok := option.(IgnoreLinkPolicy); ok {
+			overrides.IgnoreLinkPolicy = instance
+			found = true
+		}
+		if instance, ok := option.(CleanLinkQueryParamsPolicy); ok {
+			overrides.CleanLinkQueryParamsPolicy = instance
+			found = true
+		}
+		if instance, ok := option.(CleanLinkQueryParamValuesPolicy); ok {
+			overrides.CleanLinkQueryParamValuesPolicy = instance
+			found = true
+		}
+		if instance, ok := option.(FollowRedirectsInHTMLContentPolicy); ok {
+			overrides.FollowRedirectsInHTMLContentPolicy = instance
+			found = true
+		}
+		if instance, ok := option.(Accept); ok {
+			overrides.AcceptHeader = string(instance)
+			found = true
+		}
+	}
+	if !found {
+		return ctx
+	}
+	return context.WithValue(ctx, traversalPoliciesContextKey{}, overrides)
+}
+
+// policies returns the policies in effect for ctx: those passed as TraverseLink options take precedence over the
+// factory's own policies, one policy at a time
+func (f *DefaultFactory) policies(ctx context.Context) traversalPolicies {
+	result := traversalPolicies{f.IgnoreLinkPolicy, f.CleanLinkQueryParamsPolicy, f.CleanLinkQueryParamValuesPolicy, f.FollowRedirectsInHTMLContentPolicy, f.AcceptHeader}
+	overrides, ok := ctx.Value(traversalPoliciesContextKey{}).(*traversalPolicies)
+	if !ok {
+		return result
+	}
+	if overrides.IgnoreLinkPolicy != nil {
+		result.IgnoreLinkPolicy = overrides.IgnoreLinkPolicy
+	}
+	if overrides.CleanLinkQueryParamsPolicy != nil {
+		result.CleanLinkQueryParamsPolicy = overrides.CleanLinkQueryParamsPolicy
+	}
+	if overrides.CleanLinkQueryParamValuesPolicy != nil {
+		result.CleanLinkQueryParamValuesPolicy = overrides.CleanLinkQueryParamValuesPolicy
+	}
+	if overrides.FollowRedirectsInHTMLContentPolicy != nil {
+		result.FollowRedirectsInHTMLContentPolicy = overrides.FollowRedirectsInHTMLContentPolicy
+	}
+	if len(overrides.AcceptHeader) > 0 {
+		result.AcceptHeader = overrides.AcceptHeader
+	}
+	return result
+}
+
+// DefaultHTTPClientTimeout is the timeout of the client NewFactory uses when no client or RoundTripper is given (the same as resource's)
+const DefaultHTTPClientTimeout = 90 * time.Second
+
+// HTTPClientProvider supplies the HTTP client used to fetch links; a bare *http.Client, http.RoundTripper or
+// func(context.Context) *http.Client may also be passed as an option.
+// It has the same signature as resource.HTTPClientProvider but the client is requested once, with context.Background(), when the
+// factory is created; the context of each traversal is attached to its requests instead.
+// The factory wraps the client's transport so that data: URLs (and file: URLs, if allowed) are read locally instead of being fetched.
+type HTTPClientProvider interface {
+	HTTPClient(ctx context.Context) *http.Client
+}
+
+// httpClientProvider adapts a bare *http.Client to the HTTPClientProvider interface
+type httpClientProvider struct {
+	client *http.Client
+}
+
+// HTTPClient returns the wrapped client
+func (p httpClientProvider) HTTPClient(context.Context) *http.Client {
+	return p.client
+}
+
+// httpClientProviderFunc adapts a func(context.Context) *http.Client option to the HTTPClientProvider interface
+type httpClientProviderFunc func(ctx context.Context) *http.Client
+
+// HTTPClient calls the wrapped func
+func (p httpClientProviderFunc) HTTPClient(ctx context.Context) *http.Client {
+	return p(ctx)
+}
+
+// resourceFetcher gives the resource factory the factory's wrapped client and attaches the traversal's context to
+// each request, since resource.PageFromURL creates its requests without one; the context carries the httpTrace and
+// per-call overrides which the client's transport relies on. It's also the resource factory's download error policy
+// so that attachment download errors, which resource otherwise drops, are recorded on the httpTrace.
+type resourceFetcher struct {
+	client      *http.Client
+	preparer    resource.HTTPRequestPreparer          // the last preparer passed as an option, if any
+	errorPolicy resource.ContentDownloaderErrorPolicy // the last download error policy passed as an option, if any
+}
+
+// newResourceFetcher creates a resourceFetcher which chains the last resource.HTTPRequestPreparer and
+// resource.ContentDownloaderErrorPolicy in options
+func newResourceFetcher(client *http.Client, options []interface{}) resourceFetcher {
+	fetcher := resourceFetcher{client: client}
+	for _, option := range options {
+		if instance, ok := option.(resource.HTTPRequestPreparer); ok {
+			fetcher.preparer = instance
+		}
+		if instance, ok := option.(resource.ContentDownloaderErrorPolicy); ok {
+			fetcher.errorPolicy = instance
+		}
+	}
+	return fetcher
+}
+
+// HTTPClient satisfies resource.HTTPClientProvider
+func (r resourceFetcher) HTTPClient(context.Context) *http.Client {
+	return r.client
+}
+
+// OnPrepareHTTPRequest satisfies resource.HTTPRequestPreparer
+func (r resourceFetcher) OnPrepareHTTPRequest(ctx context.Context, client *http.Client, req *http.Request) {
+	*req = *req.WithContext(ctx)
+	if r.preparer != nil {
+		r.preparer.OnPrepareHTTPRequest(ctx, client, req)
+	}
+}
+
+// StopOnDownloadError satisfies resource.ContentDownloaderErrorPolicy, recording the error for the traversal and
+// only stopping if the caller's own policy says so
+func (r resourceFetcher) StopOnDownloadError(ctx context.Context, url *url.URL, t resource.Type, err error) bool {
+	stop := r.errorPolicy != nil && r.errorPolicy.StopOnDownloadError(ctx, url, t, err)
+	if trace := httpTraceFromContext(ctx); trace != nil {
+		trace.mutex.Lock()
+		trace.downloadErr = err
+		trace.downloadStopped = stop
+		trace.mutex.Unlock()
+	}
+	return stop
+}
+
+// PreRequestURLRewriter rewrites a URL immediately before it's requested, e.g. to append an API key or route the
+// request through a read-proxy. Returning the same URL is a no-op. It's called for every outgoing HTTP request,
+// including redirect hops, after UnwrapURLsRules were applied. Cleaning only happens after the fetch (it applies
+// to the resolved URL) so the rewriter sees URLs before they're cleaned. The rewritten URL is never recorded:
+// ResolvedURL and FinalizedURL are derived from the URL as it was before rewriting.
+type PreRequestURLRewriter func(ctx context.Context, url *url.URL) *url.URL
+
+// HostConnectionLimits may be passed as an option to NewFactory to set MaxConnsPerHost and MaxIdleConnsPerHost
+type HostConnectionLimits struct {
+	MaxConns     int
+	MaxIdleConns int
+}
+
 type DefaultFactory struct {
 	IgnoreURLsRegExprs        []*regexp.Regexp `json:"ignoreURLsRegExprs"`
 	RemoveParamsFromURLsRegEx []*regexp.Regexp `json:"removeParamsFromURLsRegEx"`
+	RemoveParamValuesRegEx    []*regexp.Regexp `json:"removeParamValuesRegEx"`
+
+	// UnwrapURLsRules replace a wrapper URL by the URL in its query param before it's fetched, so the inner URL is
+	// the one which is resolved, ignored and cleaned; see TraversedLink.UnwrappedURLText
+	UnwrapURLsRules []URLWrapperRule `json:"unwrapURLsRules"`
+
+	// AllowedSchemes lists the URL schemes which may be traversed (http and https by default); add "data" to
+	// traverse data: URLs, file: URLs additionally require AllowFileScheme
+	AllowedSchemes []string `json:"allowedSchemes"`
+
+	// EnableCleaning turns URL cleaning on (the default); when it's off FinalizedURL is always the ResolvedURL,
+	// regardless of the cleaning rules and policies
+	EnableCleaning bool `json:"enableCleaning"`
+
+	// SemicolonSeparatesQueryParams treats `;` as an alternative to `&` when cleaning query params (off by default to match net/url)
+	SemicolonSeparatesQueryParams bool `json:"semicolonSeparatesQueryParams"`
+
+	// CleanFragmentParams also cleans query-like URL fragments (`#utm_source=...` or `#/path?utm_source=...`)
+	CleanFragmentParams bool `json:"cleanFragmentParams"`
+
+	// AllowFileScheme lets file:// URLs be read from the local disk; keep it off when traversing untrusted input.
+	// Redirects to file:// URLs are always rejected.
+	AllowFileScheme bool `json:"allowFileScheme"`
+
+	// SkipTLSInfo avoids retaining certificate details of HTTPS links in TraversedLink.TLSInfo
+	SkipTLSInfo bool `json:"skipTLSInfo"`
+
+	// WarnOnHostChange records an Issue when a link's finalized URL is on a different host than the original URL (or
+	// the URL unwrapped from it)
+	// (unlike WasShortened, any host change counts, e.g. example.com to www.example.com)
+	WarnOnHostChange bool `json:"warnOnHostChange"`
+
+	// WarnTLSExpiryWithin records an Issue when an HTTPS link's certificate expires within this window (0 disables the check)
+	WarnTLSExpiryWithin time.Duration `json:"warnTLSExpiryWithin"`
+
+	// MaxConnsPerHost and MaxIdleConnsPerHost bound the connections the client's *http.Transport opens to (or keeps
+	// idle for) a single host; 0 keeps the transport's setting. They're applied to a clone of the transport when the
+	// factory is created so pass HostConnectionLimits as an option to NewFactory to set them.
+	MaxConnsPerHost     int `json:"maxConnsPerHost"`
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+
+	// AllowRedirectsWithoutLocation lets CheckLink accept 3xx responses without a Location header as valid
+	// destinations; by default they're reported as invalid (LECTIOLINK-011-REDIRECTWITHOUTLOCATION) since their
+	// content is unusable. TraverseLink can't inspect their content either way so it only uses the generic code when it's set.
+	AllowRedirectsWithoutLocation bool `json:"allowRedirectsWithoutLocation"`
+
+	// ReportAllIgnoreRules records an Issue (LECTIOLINK-013-IGNORERULEMATCHED) for each of the IgnoreURLsRegExprs
+	// which match an ignored link; IgnoreReason and IgnoreRule still describe the first match
+	ReportAllIgnoreRules bool `json:"reportAllIgnoreRules"`
+
+	// RecordRedirectHops keeps the status, Location and Set-Cookie headers of every HTTP redirect in TraversedLink.RedirectHops
+	RecordRedirectHops bool `json:"recordRedirectHops"`
+
+	// AcceptHeader is sent with HTTP requests which don't set their own Accept header (empty sends none); an Accept
+	// passed as a TraverseLink option overrides it for that call
+	AcceptHeader string `json:"acceptHeader"`
 
 	ResourceFactory                    resource.Factory
 	IgnoreLinkPolicy                   IgnoreLinkPolicy
 	CleanLinkQueryParamsPolicy         CleanLinkQueryParamsPolicy
+	CleanLinkQueryParamValuesPolicy    CleanLinkQueryParamValuesPolicy
 	FollowRedirectsInHTMLContentPolicy FollowRedirectsInHTMLContentPolicy
+	HTTPClientProvider                 HTTPClientProvider
 	AttachmentsCreator                 resource.FileAttachmentCreator
+	PreRequestURLRewriter              PreRequestURLRewriter
+	Metrics                            MetricsCollector // nil unless a MetricsCollector is passed as an option
+
+	httpClient *http.Client
 }
 
 func (f *DefaultFactory) initOptions(options ...interface{}) {
@@ -75,12 +372,37 @@ func (f *DefaultFactory) initOptions(options ...interface{}) {
 		if instance, ok := option.(CleanLinkQueryParamsPolicy); ok {
 			f.CleanLinkQueryParamsPolicy = instance
 		}
+		if instance, ok := option.(CleanLinkQueryParamValuesPolicy); ok {
+			f.CleanLinkQueryParamValuesPolicy = instance
+		}
 		if instance, ok := option.(FollowRedirectsInHTMLContentPolicy); ok {
 			f.FollowRedirectsInHTMLContentPolicy = instance
 		}
+		if instance, ok := option.(HTTPClientProvider); ok {
+			f.HTTPClientProvider = instance
+		}
+		if instance, ok := option.(*http.Client); ok {
+			f.HTTPClientProvider = httpClientProvider{instance}
+		}
+		if instance, ok := option.(http.RoundTripper); ok {
+			f.HTTPClientProvider = httpClientProvider{&http.Client{Transport: instance}}
+		}
+		if instance, ok := option.(func(context.Context) *http.Client); ok {
+			f.HTTPClientProvider = httpClientProviderFunc(instance)
+		}
+		if instance, ok := option.(PreRequestURLRewriter); ok {
+			f.PreRequestURLRewriter = instance
+		}
 		if instance, ok := option.(resource.FileAttachmentCreator); ok {
 			f.AttachmentsCreator = instance
 		}
+		if instance, ok := option.(MetricsCollector); ok {
+			f.Metrics = instance
+		}
+		if instance, ok := option.(HostConnectionLimits); ok {
+			f.MaxConnsPerHost = instance.MaxConns
+			f.MaxIdleConnsPerHost = instance.MaxIdleConns
+		}
 	}
 }
 
@@ -91,13 +413,19 @@ func (f *DefaultFactory) FollowRedirectsInHTMLContent(context.Context, *url.URL)
 
 // IgnoreLink returns true (and a reason) if the given url should be ignored by the harvester
 func (f *DefaultFactory) IgnoreLink(ctx context.Context, url *url.URL) (bool, string) {
+	ignore, reason, _ := f.IgnoreLinkRule(ctx, url)
+	return ignore, reason
+}
+
+// IgnoreLinkRule is like IgnoreLink but also returns the IgnoreURLsRegExprs entry which matched
+func (f *DefaultFactory) IgnoreLinkRule(ctx context.Context, url *url.URL) (bool, string, *regexp.Regexp) {
 	URLtext := url.String()
 	for _, regEx := range f.IgnoreURLsRegExprs {
 		if regEx.MatchString(URLtext) {
-			return true, fmt.Sprintf("Matched Ignore Rule `%s`", regEx.String())
+			return true, fmt.Sprintf("Matched Ignore Rule `%s`", regEx.String()), regEx
 		}
 	}
-	return false, ""
+	return false, "", nil
 }
 
 // CleanLinkParams returns true if the given url's query string param should be "cleaned" by the harvester
@@ -117,48 +445,132 @@ func (f *DefaultFactory) RemoveQueryParamFromLinkURL(ctx context.Context, url *u
 	return false, ""
 }
 
-// TraverseLink creates a content instance from the given URL
+// RemoveQueryParamValueFromLinkURL returns true (and a reason) if the given url's specific query string param should be "cleaned" by the harvester because of its value
+func (f *DefaultFactory) RemoveQueryParamValueFromLinkURL(ctx context.Context, url *url.URL, paramName string, paramValue string) (bool, string) {
+	for _, regEx := range f.RemoveParamValuesRegEx {
+		if regEx.MatchString(paramValue) {
+			return true, fmt.Sprintf("Matched cleaner value rule %q for param %q: %q", regEx.String(), paramName, url.String())
+		}
+	}
+
+	return false, ""
+}
+
+// TraverseLink creates a content instance from the given URL. An IgnoreLinkPolicy, CleanLinkQueryParamsPolicy,
+// CleanLinkQueryParamValuesPolicy, FollowRedirectsInHTMLContentPolicy (e.g. FollowHTMLRedirects(false)) or Accept
+// passed in options overrides the factory's setting of the same kind for this call only, including any HTML redirect it follows.
 func (f *DefaultFactory) TraverseLink(ctx context.Context, origURLtext string, options ...interface{}) (bool, Link, error) {
+	start := time.Now()
+	traversable, link, err := f.traverseLink(ctx, origURLtext, options...)
+	if traversed, ok := link.(*TraversedLink); ok {
+		f.checkHostChanged(traversed)
+	}
+	if f.Metrics != nil {
+		f.Metrics.ObserveDuration(time.Since(start))
+		f.Metrics.IncTraversed(traversalOutcome(link))
+	}
+	return traversable, link, err
+}
+
+// traverseLink implements TraverseLink; HTML redirects are followed recursively as part of the same traversal
+func (f *DefaultFactory) traverseLink(ctx context.Context, origURLtext string, options ...interface{}) (bool, Link, error) {
+	ctx = withPolicyOverrides(ctx, options)
 	result := new(TraversedLink)
 	result.OrigURLText = origURLtext
 	result.TraversedOn = time.Now()
+	result.DeclaredLength = -1
+
+	if allowed, scheme := f.isSchemeAllowed(origURLtext); !allowed {
+		result.IsURLValid = false
+		result.IsURLIgnored = true
+		result.InvalidURLCode = "LECTIOLINK-008-SCHEMENOTALLOWED"
+		result.IgnoreReason = fmt.Sprintf("URL scheme %q is not allowed", scheme)
+		return false, result, xerrors.Errorf("Unable to create page from URL %q: scheme %q is not allowed", origURLtext, scheme)
+	}
 
+	fetchURLText := origURLtext
+	if unwrapped, ok := f.unwrapURL(origURLtext); ok {
+		result.UnwrappedURLText = unwrapped
+		fetchURLText = unwrapped
+	}
+
+	fetchCtx, trace := withHTTPTrace(ctx)
 	var err error
-	result.Content, err = f.ResourceFactory.PageFromURL(ctx, origURLtext, options...)
+	result.Content, err = f.ResourceFactory.PageFromURL(fetchCtx, fetchURLText, options...)
+	result.HTTPRedirectCount = trace.redirectCount
+	result.RedirectChain = collapseRedirectChain(trace.redirectURLs)
+	result.RedirectHops = trace.redirectHops
+	result.HTTPStatusCode = trace.statusCode
+	result.TLSInfo = newTLSInfo(trace.tls)
+	result.ContentTypeSniffed = trace.contentTypeSniffed
+	result.DeclaredLength = trace.declaredLength
+	result.dispositionType = trace.dispositionType
+	if trace.downloadErr != nil {
+		issue := Issue{"LECTIOLINK-015-INVALIDATTACHMENT",
+			fmt.Sprintf("Content of %q (Content-Type %q) could not be downloaded: %v", origURLtext, trace.contentType, trace.downloadErr)}
+		if xerrors.Is(trace.downloadErr, ErrDownloadBudgetExceeded) {
+			issue = Issue{"LECTIOLINK-016-DOWNLOADBUDGETEXCEEDED",
+				fmt.Sprintf("Content of %q (Content-Type %q) was not downloaded: %v", origURLtext, trace.contentType, trace.downloadErr)}
+		}
+		result.Issues = append(result.Issues, issue)
+
+		// resource only returns the download error when a ContentDownloaderErrorPolicy passed as an option asked to stop
+		if trace.downloadStopped {
+			result.IsURLValid = false
+			result.IsURLIgnored = true
+			result.InvalidURLCode = issue.Code
+			result.IgnoreReason = "Content download stopped by ContentDownloaderErrorPolicy"
+			return false, result, xerrors.Errorf("Unable to download content of URL %q: %w", origURLtext, err)
+		}
+	}
+	if err != nil && result.Content != nil {
+		// resource returns the content along with the error when the response arrived but its media type couldn't
+		// be parsed; the URL is still a valid destination
+		result.Issues = append(result.Issues, Issue{"LECTIOLINK-014-INVALIDCONTENT",
+			fmt.Sprintf("Content of %q (Content-Type %q) is not valid: %v", origURLtext, trace.contentType, err)})
+		err = nil
+	}
 	result.IsURLValid = err == nil
 	if result.IsURLValid == false {
 		result.IsURLIgnored = true
-		result.IgnoreReason = "Unable to construct URL"
+		// resource rejects every status other than 200, so a redirect the client couldn't follow arrives as an error
+		if f.isRedirectWithoutLocation(trace.statusCode, trace.location) {
+			result.InvalidURLCode = "LECTIOLINK-011-REDIRECTWITHOUTLOCATION"
+			result.IgnoreReason = fmt.Sprintf("HTTP status %d redirect without a Location header", trace.statusCode)
+			return false, result, xerrors.Errorf("Unable to create page from URL %q: %s", origURLtext, result.IgnoreReason)
+		}
+		result.InvalidURLCode, result.IgnoreReason = invalidURLCode(err)
 		return false, result, xerrors.Errorf("Unable to create page from URL: %w", err)
 	}
 
-	result.ResolvedURL = result.Content.URL()
+	f.checkTLSExpiry(result)
+	if len(trace.contentTypes) > 1 {
+		result.Issues = append(result.Issues, Issue{"LECTIOLINK-010-MULTIPLECONTENTTYPES",
+			fmt.Sprintf("Server sent %d Content-Type headers (%s) for %q, used %q", len(trace.contentTypes), strings.Join(trace.contentTypes, ", "), origURLtext, trace.contentType)})
+	}
+
+	result.ResolvedURL = normalizeURL(result.Content.URL())
 	result.FinalizedURL = result.ResolvedURL
-	ignoreURL, ignoreReason := f.IgnoreLinkPolicy.IgnoreLink(ctx, result.ResolvedURL)
+	ignoreURL, ignoreReason, ignoreRule := f.ignoreLink(ctx, result.ResolvedURL)
 	if ignoreURL {
 		result.IsURLIgnored = true
 		result.IgnoreReason = ignoreReason
+		result.IgnoreRule = ignoreRule
+		f.reportIgnoreRules(ctx, result)
 		return false, result, nil
 	}
 
 	result.IsURLIgnored = false
-	urlsParamsCleaned, cleanedURL := f.cleanLink(ctx, result.ResolvedURL)
-	if urlsParamsCleaned {
-		result.CleanedURL = cleanedURL
-		result.FinalizedURL = cleanedURL
-		result.AreURLParamsCleaned = true
-	} else {
-		result.AreURLParamsCleaned = false
-	}
+	f.cleanTraversedLink(ctx, result)
 
 	// TODO: once the URL is cleaned, double-check the cleaned URL to see if it's a valid destination; if not, revert to non-cleaned version
 	// this could be done recursively here or by the outer function. This is necessary because "cleaning" a URL and removing params might
 	// break it so we need to revert to original.
 
-	if f.FollowRedirectsInHTMLContentPolicy.FollowRedirectsInHTMLContent(ctx, result.FinalizedURL) {
-		isHTMLRedirect, htmlRedirectURL := result.Content.Redirect()
+	if f.policies(ctx).FollowRedirectsInHTMLContentPolicy.FollowRedirectsInHTMLContent(ctx, result.FinalizedURL) {
+		isHTMLRedirect, htmlRedirectURL := result.Redirect()
 		if isHTMLRedirect {
-			traversable, redirLink, redirErr := f.TraverseLink(ctx, htmlRedirectURL, options...)
+			traversable, redirLink, redirErr := f.traverseLink(ctx, htmlRedirectURL.String(), options...)
 			redirected := redirLink.(*TraversedLink)
 			redirected.OrigLink = result
 			return traversable, redirected, redirErr
@@ -168,35 +580,381 @@ func (f *DefaultFactory) TraverseLink(ctx context.Context, origURLtext string, o
 	return true, result, nil
 }
 
+// Preview applies the ignore and cleaning policies to urlText without fetching it, so the effect of
+// IgnoreURLsRegExprs and RemoveParamsFromURLsRegEx can be shown interactively. normalized is the URL that
+// TraverseLink would finalize to if the server didn't redirect (cleaned unless it was ignored). Policies passed
+// in options override the factory's as they do in TraverseLink.
+func (f *DefaultFactory) Preview(ctx context.Context, urlText string, options ...interface{}) (normalized *url.URL, ignored bool, ignoreReason string, cleanedParams []CleanedParam, err error) {
+	if allowed, scheme := f.isSchemeAllowed(urlText); !allowed {
+		return nil, false, "", nil, xerrors.Errorf("Unable to preview URL %q: scheme %q is not allowed", urlText, scheme)
+	}
+
+	if unwrapped, ok := f.unwrapURL(urlText); ok {
+		urlText = unwrapped
+	}
+	parsedURL, err := url.Parse(urlText)
+	if err != nil {
+		return nil, false, "", nil, xerrors.Errorf("Unable to preview URL %q: %w", urlText, err)
+	}
+
+	ctx = withPolicyOverrides(ctx, options)
+	normalized = normalizeURL(parsedURL)
+	if ignored, ignoreReason, _ = f.ignoreLink(ctx, normalized); ignored {
+		return normalized, true, ignoreReason, nil, nil
+	}
+
+	if cleaned, cleanedURL, params := f.cleanLink(ctx, normalized); cleaned {
+		return cleanedURL, false, "", params, nil
+	}
+	return normalized, false, "", nil, nil
+}
+
+// unwrapURL returns the URL wrapped by urlText if it matches one of UnwrapURLsRules; nested wrappers are unwrapped
+// too. Only absolute http(s) URLs are accepted as the wrapped URL.
+func (f *DefaultFactory) unwrapURL(urlText string) (string, bool) {
+	unwrapped := false
+	for depth := 0; depth < 5; depth++ {
+		inner, ok := f.unwrapURLOnce(urlText)
+		if !ok {
+			break
+		}
+		urlText, unwrapped = inner, true
+	}
+	return urlText, unwrapped
+}
+
+// unwrapURLOnce applies the first matching rule of UnwrapURLsRules whose query param holds an http(s) URL
+func (f *DefaultFactory) unwrapURLOnce(urlText string) (string, bool) {
+	for _, rule := range f.UnwrapURLsRules {
+		if !rule.Pattern.MatchString(urlText) {
+			continue
+		}
+		wrapperURL, err := url.Parse(urlText)
+		if err != nil {
+			return "", false
+		}
+		inner := wrapperURL.Query().Get(rule.QueryParam)
+		innerURL, err := url.Parse(inner)
+		if err != nil || !innerURL.IsAbs() || len(innerURL.Host) == 0 {
+			continue
+		}
+		if scheme := strings.ToLower(innerURL.Scheme); scheme == "http" || scheme == "https" {
+			return inner, true
+		}
+	}
+	return "", false
+}
+
+// reportIgnoreRules records an Issue for every IgnoreURLsRegExprs rule matching an ignored link when ReportAllIgnoreRules
+// is set; only the DefaultFactory ignore policy has rules to report
+func (f *DefaultFactory) reportIgnoreRules(ctx context.Context, result *TraversedLink) {
+	if !f.ReportAllIgnoreRules {
+		return
+	}
+	policy, ok := f.policies(ctx).IgnoreLinkPolicy.(*DefaultFactory)
+	if !ok {
+		return
+	}
+
+	URLtext := result.ResolvedURL.String()
+	for _, regEx := range policy.IgnoreURLsRegExprs {
+		if regEx.MatchString(URLtext) {
+			result.Issues = append(result.Issues, Issue{"LECTIOLINK-013-IGNORERULEMATCHED",
+				fmt.Sprintf("Matched Ignore Rule `%s`", regEx.String())})
+		}
+	}
+}
+
+// ignoreLink runs the ignore policy in effect for ctx, including the matched rule when the policy reports one
+func (f *DefaultFactory) ignoreLink(ctx context.Context, url *url.URL) (bool, string, *regexp.Regexp) {
+	ignorePolicy := f.policies(ctx).IgnoreLinkPolicy
+	if rulePolicy, ok := ignorePolicy.(IgnoreLinkRulePolicy); ok {
+		return rulePolicy.IgnoreLinkRule(ctx, url)
+	}
+	ignore, reason := ignorePolicy.IgnoreLink(ctx, url)
+	return ignore, reason, nil
+}
+
+// TraverseLinkStrict is like TraverseLink but also returns an error wrapping ErrLinkIgnored when the link was
+// ignored, so callers which only check the error can use xerrors.Is(err, ErrLinkIgnored)
+func (f *DefaultFactory) TraverseLinkStrict(ctx context.Context, origURLtext string, options ...interface{}) (bool, Link, error) {
+	traversable, link, err := f.TraverseLink(ctx, origURLtext, options...)
+	if err != nil {
+		return traversable, link, err
+	}
+
+	if traversed, ok := link.(*TraversedLink); ok && traversed.IsURLValid && traversed.IsURLIgnored {
+		return traversable, link, xerrors.Errorf("%s: %w", traversed.IgnoreReason, ErrLinkIgnored)
+	}
+	return traversable, link, nil
+}
+
+// CheckConnectivity makes a minimal request to probeURL using the factory's client (and any proxy it's configured with)
+// so batches can fail fast when outbound connectivity, DNS or the proxy is broken. It respects ctx's deadline.
+// A 407 (the proxy refused the request) or 5xx response (e.g. a proxy or gateway which can't reach the internet)
+// is a failure; any other response, including other 4xx statuses of the probe URL itself, proves connectivity.
+func (f *DefaultFactory) CheckConnectivity(ctx context.Context, probeURL string) error {
+	req, err := http.NewRequest(http.MethodHead, probeURL, nil)
+	if err != nil {
+		return xerrors.Errorf("Unable to create connectivity probe request for %q: %w", probeURL, err)
+	}
+
+	resp, err := f.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		code, reason := invalidURLCode(err)
+		return xerrors.Errorf("%s %s while probing %q: %w", code, reason, probeURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode >= http.StatusInternalServerError {
+		return xerrors.Errorf("LECTIOLINK-009-HTTPERROR HTTP status %s while probing %q", resp.Status, probeURL)
+	}
+	return nil
+}
+
+// CheckLink validates urlText with a HEAD request (or, when HEAD isn't supported, a GET for just the first byte)
+// and records the status, redirects and resolved URL without downloading or inspecting the body, which is much
+// cheaper than TraverseLink for link checkers. Content is always nil and the ignore and cleaning policies aren't
+// applied. Responses with an HTTP status of 400 or above are reported as invalid.
+func (f *DefaultFactory) CheckLink(ctx context.Context, urlText string) (*TraversedLink, error) {
+	result := new(TraversedLink)
+	result.OrigURLText = urlText
+	result.TraversedOn = time.Now()
+	result.DeclaredLength = -1
+
+	if allowed, scheme := f.isSchemeAllowed(urlText); !allowed {
+		result.IsURLIgnored = true
+		result.InvalidURLCode = "LECTIOLINK-008-SCHEMENOTALLOWED"
+		result.IgnoreReason = fmt.Sprintf("URL scheme %q is not allowed", scheme)
+		return result, xerrors.Errorf("Unable to check URL %q: scheme %q is not allowed", urlText, scheme)
+	}
+
+	resp, trace, err := f.probeLink(ctx, http.MethodHead, urlText)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, trace, err = f.probeLink(ctx, http.MethodGet, urlText)
+	}
+	result.HTTPRedirectCount = trace.redirectCount
+	result.RedirectChain = collapseRedirectChain(trace.redirectURLs)
+	result.RedirectHops = trace.redirectHops
+	result.HTTPStatusCode = trace.statusCode
+	result.TLSInfo = newTLSInfo(trace.tls)
+	result.DeclaredLength = trace.declaredLength
+	if err != nil {
+		result.IsURLIgnored = true
+		result.InvalidURLCode, result.IgnoreReason = invalidURLCode(err)
+		return result, xerrors.Errorf("Unable to check URL: %w", err)
+	}
+
+	result.HTTPStatusCode = resp.StatusCode
+	result.ResolvedURL = normalizeURL(resp.Request.URL)
+	result.FinalizedURL = result.ResolvedURL
+	if resp.StatusCode >= http.StatusBadRequest {
+		result.IsURLIgnored = true
+		result.InvalidURLCode = "LECTIOLINK-009-HTTPERROR"
+		result.IgnoreReason = fmt.Sprintf("HTTP status %s", resp.Status)
+		return result, nil
+	}
+	if f.isRedirectWithoutLocation(resp.StatusCode, resp.Header.Get("Location")) {
+		result.IsURLIgnored = true
+		result.InvalidURLCode = "LECTIOLINK-011-REDIRECTWITHOUTLOCATION"
+		result.IgnoreReason = fmt.Sprintf("HTTP status %s redirect without a Location header", resp.Status)
+		return result, nil
+	}
+
+	result.IsURLValid = true
+	f.checkTLSExpiry(result)
+	return result, nil
+}
+
+// probeLink requests urlText without reading the response body; GET requests only ask for the first byte
+func (f *DefaultFactory) probeLink(ctx context.Context, method string, urlText string) (*http.Response, *httpTrace, error) {
+	fetchCtx, trace := withHTTPTrace(ctx)
+	req, err := http.NewRequest(method, urlText, nil)
+	if err != nil {
+		return nil, trace, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := f.httpClient.Do(req.WithContext(fetchCtx))
+	if err != nil {
+		return nil, trace, err
+	}
+	resp.Body.Close()
+	return resp, trace, nil
+}
+
+// isRedirectWithoutLocation returns true for a malformed 3xx response which the client couldn't follow because it
+// had no Location header, unless AllowRedirectsWithoutLocation is set; 304 Not Modified never has one
+func (f *DefaultFactory) isRedirectWithoutLocation(statusCode int, location string) bool {
+	return !f.AllowRedirectsWithoutLocation && statusCode >= 300 && statusCode < 400 &&
+		statusCode != http.StatusNotModified && len(location) == 0
+}
+
+// isSchemeAllowed returns true if the URL's scheme is in AllowedSchemes (or is file: and AllowFileScheme is set);
+// unparseable URLs are left for the resource factory to report
+func (f *DefaultFactory) isSchemeAllowed(urlText string) (bool, string) {
+	parsedURL, err := url.Parse(urlText)
+	if err != nil {
+		return true, ""
+	}
+
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if scheme == "file" && f.AllowFileScheme {
+		return true, scheme
+	}
+	for _, allowed := range f.AllowedSchemes {
+		if scheme == strings.ToLower(allowed) {
+			return true, scheme
+		}
+	}
+	return false, scheme
+}
+
+// checkTLSExpiry records an Issue if the link's certificate expires within WarnTLSExpiryWithin; it doesn't affect traversability
+func (f *DefaultFactory) checkTLSExpiry(result *TraversedLink) {
+	if f.WarnTLSExpiryWithin <= 0 || result.TLSInfo == nil {
+		return
+	}
+
+	if remaining := time.Until(result.TLSInfo.NotAfter); remaining < f.WarnTLSExpiryWithin {
+		result.Issues = append(result.Issues, Issue{"LECTIOLINK-007-TLSEXPIRING",
+			fmt.Sprintf("TLS certificate for %q (issued by %s) expires %s", result.OrigURLText, result.TLSInfo.Issuer, result.TLSInfo.NotAfter.Format(time.RFC3339))})
+	}
+}
+
+// checkHostChanged records an Issue if WarnOnHostChange is set and the finalized URL's host differs from the host
+// of the first requested URL (following OrigLink back to the first link, and using its unwrapped URL if
+// UnwrapURLsRules applied); it doesn't affect traversability
+func (f *DefaultFactory) checkHostChanged(result *TraversedLink) {
+	if !f.WarnOnHostChange || result.FinalizedURL == nil {
+		return
+	}
+
+	orig := result
+	for orig.OrigLink != nil {
+		orig = orig.OrigLink
+	}
+	origURL, err := url.Parse(orig.requestedURLText())
+	if err != nil || len(origURL.Hostname()) == 0 {
+		return
+	}
+
+	origHost, finalHost := normalizeURL(origURL).Hostname(), normalizeURL(result.FinalizedURL).Hostname()
+	if origHost != finalHost {
+		result.Issues = append(result.Issues, Issue{"LECTIOLINK-012-HOSTCHANGED",
+			fmt.Sprintf("Host changed from %q to %q while traversing %q", origHost, finalHost, orig.OrigURLText)})
+	}
+}
+
+// cleanTraversedLink cleans the link's resolved URL and records the result (and any cleaning issues) on the link
+func (f *DefaultFactory) cleanTraversedLink(ctx context.Context, result *TraversedLink) {
+	urlsParamsCleaned, cleanedURL, cleanedParams := f.cleanLink(ctx, result.ResolvedURL)
+	if !urlsParamsCleaned {
+		result.AreURLParamsCleaned = false
+		return
+	}
+
+	result.CleanedURL = cleanedURL
+	result.FinalizedURL = cleanedURL
+	result.AreURLParamsCleaned = true
+	result.CleanedParams = cleanedParams
+
+	// removing every param is sometimes a sign that a rule stripped a functional param (e.g. `/view?id=123`)
+	if len(result.ResolvedURL.RawQuery) > 0 && len(cleanedURL.RawQuery) == 0 {
+		paramNames := make([]string, len(cleanedParams))
+		for i, param := range cleanedParams {
+			paramNames[i] = param.ParamName
+		}
+		sort.Strings(paramNames)
+		result.Issues = append(result.Issues, Issue{"LECTIOLINK-006-ALLPARAMSCLEANED",
+			fmt.Sprintf("Cleaning removed all query params (%s) from %q", strings.Join(paramNames, ", "), result.ResolvedURL.String())})
+	}
+}
+
 // cleanLink checks to see if there are any parameters that should be removed (e.g. UTM_*)
-func (f *DefaultFactory) cleanLink(ctx context.Context, url *url.URL) (bool, *url.URL) {
-	if !f.CleanLinkQueryParamsPolicy.CleanLinkParams(ctx, url) {
-		return false, nil
+func (f *DefaultFactory) cleanLink(ctx context.Context, url *url.URL) (bool, *url.URL, []CleanedParam) {
+	if !f.EnableCleaning || !f.policies(ctx).CleanLinkQueryParamsPolicy.CleanLinkParams(ctx, url) {
+		return false, nil, nil
 	}
 
 	// make a copy because we're planning on changing the URL params
 	cleanedURL, error := url.Parse(url.String())
 	if error != nil {
-		return false, nil
+		return false, nil, nil
 	}
 
-	harvestedParams := cleanedURL.Query()
-	type ParamMatch struct {
-		paramName string
-		reason    string
+	if f.SemicolonSeparatesQueryParams {
+		cleanedURL.RawQuery = strings.Replace(cleanedURL.RawQuery, ";", "&", -1)
 	}
-	var cleanedParams []ParamMatch
-	for paramName := range harvestedParams {
-		remove, reason := f.CleanLinkQueryParamsPolicy.RemoveQueryParamFromLinkURL(ctx, url, paramName)
+
+	harvestedParams := cleanedURL.Query()
+	var cleanedParams []CleanedParam
+	for paramName, paramValues := range harvestedParams {
+		remove, reason := f.removeQueryParam(ctx, url, paramName, paramValues)
 		if remove {
 			harvestedParams.Del(paramName)
-			cleanedParams = append(cleanedParams, ParamMatch{paramName, reason})
+			cleanedParams = append(cleanedParams, CleanedParam{paramName, reason})
 		}
 	}
-
 	if len(cleanedParams) > 0 {
 		cleanedURL.RawQuery = harvestedParams.Encode()
-		return true, cleanedURL
 	}
-	return false, nil
+
+	if f.CleanFragmentParams {
+		cleanedParams = append(cleanedParams, f.cleanFragment(ctx, url, cleanedURL)...)
+	}
+
+	if len(cleanedParams) > 0 {
+		return true, cleanedURL, cleanedParams
+	}
+	return false, nil, nil
+}
+
+// removeQueryParam checks the param's name and then each of its values against the cleaning policies
+func (f *DefaultFactory) removeQueryParam(ctx context.Context, url *url.URL, paramName string, paramValues []string) (bool, string) {
+	policies := f.policies(ctx)
+	if remove, reason := policies.CleanLinkQueryParamsPolicy.RemoveQueryParamFromLinkURL(ctx, url, paramName); remove {
+		return true, reason
+	}
+	for _, paramValue := range paramValues {
+		if remove, reason := policies.CleanLinkQueryParamValuesPolicy.RemoveQueryParamValueFromLinkURL(ctx, url, paramName, paramValue); remove {
+			return true, reason
+		}
+	}
+	return false, ""
+}
+
+// cleanFragment removes params from query-like fragments (`#utm_source=...` or `#/path?utm_source=...`) used by
+// single-page apps; plain anchors such as `#section-2` are left untouched
+func (f *DefaultFactory) cleanFragment(ctx context.Context, origURL *url.URL, cleanedURL *url.URL) []CleanedParam {
+	fragmentPath, fragmentQuery := "", cleanedURL.Fragment
+	if i := strings.Index(fragmentQuery, "?"); i >= 0 {
+		fragmentPath, fragmentQuery = fragmentQuery[:i+1], fragmentQuery[i+1:]
+	} else if !strings.Contains(fragmentQuery, "=") {
+		return nil
+	}
+
+	// filter the params textually since the fragment is already unescaped and must not be re-encoded
+	var cleanedParams []CleanedParam
+	var keptParams []string
+	for _, param := range strings.Split(fragmentQuery, "&") {
+		paramNameAndValue := strings.SplitN(param, "=", 2)
+		if paramName := paramNameAndValue[0]; len(paramName) > 0 {
+			remove, reason := f.removeQueryParam(ctx, origURL, paramName, paramNameAndValue[1:])
+			if remove {
+				cleanedParams = append(cleanedParams, CleanedParam{paramName, reason})
+				continue
+			}
+		}
+		keptParams = append(keptParams, param)
+	}
+
+	if len(cleanedParams) > 0 {
+		if len(keptParams) == 0 {
+			fragmentPath = strings.TrimSuffix(fragmentPath, "?")
+		}
+		cleanedURL.Fragment = fragmentPath + strings.Join(keptParams, "&")
+	}
+	return cleanedParams
 }
@@ -1,6 +1,6 @@
 module github.com/lectio/link
 
-go 1.12
+go 1.13
 
 require (
 	github.com/lectio/resource v0.0.0-20190519022640-f60af9ad6c81
@@ -8,7 +8,7 @@ require (
 	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/crypto v0.0.0-20190513172903-22d7a77e9e5f // indirect
-	golang.org/x/net v0.0.0-20190514140710-3ec191127204 // indirect
+	golang.org/x/net v0.0.0-20190514140710-3ec191127204
 	golang.org/x/sys v0.0.0-20190516110030-61b9204099cb // indirect
 	golang.org/x/text v0.3.2 // indirect
 	golang.org/x/tools v0.0.0-20190517183331-d88f79806bbd // indirect
@@ -1,8 +1,11 @@
 package link
 
 import (
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 	"net/url"
 	"regexp"
+	"strings"
 )
 
 var defaultWebPrefixRegEx = regexp.MustCompile(`^www.`)                 // Removes "www." from start of source links
@@ -18,3 +21,54 @@ func GetSimplifiedHostnameWithoutTLD(url *url.URL) string {
 	simplified := GetSimplifiedHostname(url)
 	return defaultTopLevelDomainSuffixRegEx.ReplaceAllString(simplified, "")
 }
+
+// GetRegistrableDomain returns the URL's registrable domain (public suffix plus one label, e.g. "netspective.com" for "news.netspective.com")
+func GetRegistrableDomain(url *url.URL) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(url.Hostname())
+	if err != nil {
+		return url.Hostname()
+	}
+	return domain
+}
+
+// GetUnicodeHostname returns the URL's hostname in its Unicode display form (e.g. "münchen.de" for "xn--mnchen-3ya.de")
+func GetUnicodeHostname(url *url.URL) string {
+	hostname, err := idna.ToUnicode(url.Hostname())
+	if err != nil {
+		return url.Hostname()
+	}
+	return hostname
+}
+
+// normalizeURL returns a copy of the URL with its scheme and host lowercased and international hostnames converted to
+// their ASCII (punycode) form; the path and query are case-sensitive so they're untouched
+func normalizeURL(url *url.URL) *url.URL {
+	normalized := *url
+	normalized.Scheme = strings.ToLower(url.Scheme)
+	normalized.Host = strings.ToLower(url.Host)
+	if hostname := normalized.Hostname(); len(hostname) > 0 {
+		if asciiHostname, err := idna.ToASCII(hostname); err == nil && asciiHostname != hostname {
+			normalized.Host = strings.Replace(normalized.Host, hostname, asciiHostname, 1)
+		}
+	}
+	return &normalized
+}
+
+// collapseRedirectChain removes consecutive hops which only differ by scheme/host case or a trailing slash, returning
+// nil when no meaningful destination change remains
+func collapseRedirectChain(urls []*url.URL) []*url.URL {
+	var chain []*url.URL
+	lastKey := ""
+	for _, hop := range urls {
+		normalized := normalizeURL(hop)
+		normalized.Path = strings.TrimSuffix(normalized.Path, "/")
+		if key := normalized.String(); len(chain) == 0 || key != lastKey {
+			chain = append(chain, hop)
+			lastKey = key
+		}
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+	return chain
+}
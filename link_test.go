@@ -2,12 +2,23 @@ package link
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"github.com/spf13/afero"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lectio/resource"
 	"github.com/stretchr/testify/suite"
@@ -56,16 +67,763 @@ func (suite *LinkSuite) traverseSingleURLFromMockTweet(text string, urlText stri
 	return link.(*TraversedLink)
 }
 
+func (suite *LinkSuite) TestCustomRoundTripper() {
+	transport := &http.Transport{}
+	factory := NewFactory(suite, transport)
+	suite.Equal(transport, factory.HTTPClientProvider.HTTPClient(context.Background()).Transport, "RoundTripper option should be used by the factory's client")
+
+	client := &http.Client{Transport: transport}
+	factory = NewFactory(suite, client)
+	suite.Equal(client, factory.HTTPClientProvider.HTTPClient(context.Background()), "Client option should be used by the factory")
+
+	factory = NewFactory(suite, func(context.Context) *http.Client { return client })
+	suite.Equal(client, factory.HTTPClientProvider.HTTPClient(context.Background()), "Client func option should be used by the factory")
+
+	factory = NewFactory(suite)
+	suite.Equal(DefaultHTTPClientTimeout, factory.HTTPClientProvider.HTTPClient(context.Background()).Timeout, "Default client should time out")
+}
+
+type fixtureContextKey struct{}
+
+// fixtureTransport serves the same HTML page for every request, recording the last request it received
+type fixtureTransport struct {
+	html    string
+	request *http.Request
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.request = req
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}},
+		Body: ioutil.NopCloser(strings.NewReader(t.html)), Request: req}, nil
+}
+
+type headerPreparer string
+
+func (h headerPreparer) OnPrepareHTTPRequest(ctx context.Context, client *http.Client, req *http.Request) {
+	req.Header.Set("X-Prepared", string(h))
+}
+
+func (suite *LinkSuite) TestTraverseLinkUsesFactoryClient() {
+	transport := &fixtureTransport{html: `<html><head><meta property="og:title" content="Fixture"></head></html>`}
+	factory := NewFactory(suite, transport, headerPreparer("yes"))
+
+	ctx := context.WithValue(context.Background(), fixtureContextKey{}, "traversal")
+	traversable, link, err := factory.TraverseLink(ctx, "https://www.netspective.com/fixture")
+	suite.Nil(err, "No error expected")
+	suite.True(traversable, "URL should be traversable")
+	value, _, _ := link.(*TraversedLink).Content.MetaTag("og:title")
+	suite.Equal("Fixture", value, "The page should be fetched with the factory's transport")
+	suite.Require().NotNil(transport.request, "The factory's transport should have been used")
+	suite.Equal("traversal", transport.request.Context().Value(fixtureContextKey{}), "The traversal's context should be attached to the request")
+	suite.Equal("yes", transport.request.Header.Get("X-Prepared"), "A request preparer passed as an option should still be applied")
+}
+
+func (suite *LinkSuite) TestTraverseLinkUsesWrappedClient() {
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+			return
+		}
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	factory := NewFactory(suite)
+	traversable, link, err := factory.TraverseLink(context.Background(), server.URL+"/moved")
+	suite.Nil(err, "No error expected")
+	suite.True(traversable, "URL should be traversable")
+	suite.Equal(DefaultAcceptHeader, accept, "The page should be fetched by the factory's wrapped client")
+	suite.Equal(1, link.(*TraversedLink).HTTPRedirectCount, "The traversal's context should reach the client")
+}
+
+func (suite *LinkSuite) TestHostConnectionLimits() {
+	f := NewFactory(HostConnectionLimits{MaxConns: 2, MaxIdleConns: 1})
+	suite.Equal(2, f.MaxConnsPerHost)
+	transport, ok := f.httpClient.Transport.(*linkTransport).next.(*http.Transport)
+	suite.True(ok, "The default transport should be replaced by an *http.Transport")
+	suite.True(transport != http.DefaultTransport, "The shared default transport must not be modified")
+	suite.Equal(2, transport.MaxConnsPerHost)
+	suite.Equal(1, transport.MaxIdleConnsPerHost)
+	suite.Equal(0, http.DefaultTransport.(*http.Transport).MaxConnsPerHost)
+
+	suite.True(suite.factory.httpClient.Transport.(*linkTransport).next == http.DefaultTransport, "Without limits the transport is unchanged")
+
+	custom := &http.Transport{MaxIdleConns: 7}
+	f = NewFactory(custom, HostConnectionLimits{MaxConns: 3})
+	transport = f.httpClient.Transport.(*linkTransport).next.(*http.Transport)
+	suite.True(transport != custom, "The caller's transport must not be modified")
+	suite.Equal(0, custom.MaxConnsPerHost)
+	suite.Equal(3, transport.MaxConnsPerHost)
+	suite.Equal(7, transport.MaxIdleConns, "The caller's settings should be kept")
+	suite.True(f.ResourceFactory.(*resource.DefaultFactory).ClientProvider.HTTPClient(context.Background()) == f.httpClient, "Pages should be fetched with the limited transport")
+}
+
+func (suite *LinkSuite) TestDownloadBudget() {
+	ctx := context.Background()
+	budget := NewDownloadBudget(suite, 10)
+
+	_, file, err := budget.CreateFile(ctx, nil, nil)
+	suite.Nil(err, "File should be created while budget remains")
+	defer suite.rootFS.Remove(file.Name())
+	_, err = file.Write([]byte("123456"))
+	suite.Nil(err, "Write within budget should succeed")
+	_, err = file.Write([]byte("789012"))
+	suite.True(xerrors.Is(err, ErrDownloadBudgetExceeded), "Write beyond budget should fail")
+	suite.Equal(int64(4), budget.Remaining())
+	_, err = file.Write([]byte("7890"))
+	suite.Nil(err, "Write which exactly exhausts the budget should succeed")
+	file.Close()
+
+	_, _, err = budget.CreateFile(ctx, nil, nil)
+	suite.True(xerrors.Is(err, ErrDownloadBudgetExceeded), "No files should be created once the budget is exhausted")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4\n%%EOF\n"))
+	}))
+	defer server.Close()
+
+	factory := NewFactory(budget)
+	traversable, link, err := factory.TraverseLink(ctx, server.URL+"/paper.pdf")
+	suite.Nil(err, "Exceeding the budget should not make the link invalid")
+	suite.True(traversable, "Exceeding the budget should not make the link invalid")
+	hr := link.(*TraversedLink)
+	suite.False(hr.WasDownloaded(), "Nothing should be downloaded once the budget is exhausted")
+	suite.Require().Len(hr.Issues, 1, "Exceeding the budget should be reported")
+	suite.Equal("LECTIOLINK-016-DOWNLOADBUDGETEXCEEDED", hr.Issues[0].Code)
+}
+
+func (suite *LinkSuite) TestDataURLs() {
+	resp, err := suite.factory.httpClient.Get("data:text/html;base64,PHRpdGxlPkxlY3RpbzwvdGl0bGU+")
+	suite.Nil(err, "data: URL should not require a network call")
+	body, _ := ioutil.ReadAll(resp.Body)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("text/html", resp.Header.Get("Content-Type"))
+	suite.Equal("<title>Lectio</title>", string(body))
+
+	resp, err = suite.factory.httpClient.Get("data:,Hello%2C%20World!")
+	suite.Nil(err, "data: URL should not require a network call")
+	body, _ = ioutil.ReadAll(resp.Body)
+	suite.Equal("text/plain;charset=US-ASCII", resp.Header.Get("Content-Type"))
+	suite.Equal("Hello, World!", string(body))
+
+	factory := NewFactory(suite)
+	factory.AllowedSchemes = append(factory.AllowedSchemes, "data")
+	traversable, link, err := factory.TraverseLink(context.Background(), "data:text/html,%3Cmeta%20property%3D%22og%3Atitle%22%20content%3D%22Lectio%22%3E")
+	suite.Nil(err, "data: URL should be traversable when allowed")
+	suite.True(traversable, "data: URL should be traversable when allowed")
+	hr := link.(*TraversedLink)
+	suite.True(hr.IsHTML(), "The data: URL's media type should be used")
+	suite.Equal("Lectio", hr.DisplayTitle(), "The data: URL's content should be inspected")
+}
+
+func (suite *LinkSuite) TestFileURLs() {
+	fixture, _ := ioutil.TempFile("", "link-fixture-*.html")
+	defer os.Remove(fixture.Name())
+	fixture.WriteString("<html><head><title>Lectio</title></head></html>")
+	fixture.Close()
+
+	_, err := suite.factory.httpClient.Get("file://" + fixture.Name())
+	suite.NotNil(err, "file: URLs should be rejected by default")
+
+	suite.factory.AllowFileScheme = true
+	defer func() { suite.factory.AllowFileScheme = false }()
+	resp, err := suite.factory.httpClient.Get("file://" + fixture.Name())
+	suite.Nil(err, "file: URLs should be read when allowed")
+	body, _ := ioutil.ReadAll(resp.Body)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	suite.Equal("<html><head><title>Lectio</title></head></html>", string(body))
+
+	traversable, link, err := suite.factory.TraverseLink(context.Background(), "file://"+fixture.Name())
+	suite.Nil(err, "file: URLs should be traversable when allowed")
+	suite.True(traversable, "file: URLs should be traversable when allowed")
+	hr := link.(*TraversedLink)
+	suite.True(hr.IsHTML(), "The file's media type should be detected from its extension")
+	suite.Equal("file://"+fixture.Name(), hr.FinalizedURL.String())
+}
+
+func (suite *LinkSuite) TestHTTPRedirectsCounted() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		case "/c":
+			w.WriteHeader(http.StatusNoContent)
+		case "/d":
+			http.Redirect(w, r, "/e", http.StatusFound)
+		case "/e":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	ctx, trace := withHTTPTrace(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/a", nil)
+	resp, err := suite.factory.httpClient.Do(req.WithContext(ctx))
+	suite.Nil(err, "No error expected")
+	resp.Body.Close()
+	suite.Equal(2, trace.redirectCount, "Both HTTP redirects should be counted")
+	suite.Equal(http.StatusNoContent, trace.statusCode, "The final response's status should be recorded")
+	suite.Len(trace.redirectURLs, 3, "The requested URL and both hops should be recorded")
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/d")
+	suite.Nil(err, "No error expected")
+	suite.Equal(2, link.(*TraversedLink).HTTPRedirectCount, "Redirects should be counted by TraverseLink")
+
+	_, link, err = suite.factory.TraverseLink(context.Background(), server.URL+"/page")
+	suite.Nil(err, "No error expected")
+	suite.Equal(0, link.(*TraversedLink).HTTPRedirectCount, "A link without redirects should have a zero count")
+}
+
+func (suite *LinkSuite) TestHTTPStatusCodeRecorded() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/found")
+	suite.Nil(err, "No error expected")
+	suite.Equal(http.StatusOK, link.(*TraversedLink).HTTPStatusCode)
+
+	_, link, err = suite.factory.TraverseLink(context.Background(), server.URL+"/missing")
+	suite.NotNil(err, "An error is expected")
+	suite.False(link.(*TraversedLink).IsURLValid, "A 404 should not be valid")
+	suite.Equal(http.StatusNotFound, link.(*TraversedLink).HTTPStatusCode, "The status should be recorded for invalid links too")
+}
+
+func (suite *LinkSuite) TestRedirectWithoutLocation() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	hr, err := suite.factory.CheckLink(context.Background(), server.URL)
+	suite.Nil(err, "HTTP errors are reported on the link, not as errors")
+	suite.False(hr.IsURLValid, "A 302 without Location should not be valid")
+	suite.Equal("LECTIOLINK-011-REDIRECTWITHOUTLOCATION", hr.InvalidURLCode)
+
+	traversable, link, err := suite.factory.TraverseLink(context.Background(), server.URL)
+	suite.NotNil(err, "An error is expected")
+	suite.False(traversable, "A 302 without Location should not be traversable")
+	suite.Equal("LECTIOLINK-011-REDIRECTWITHOUTLOCATION", link.(*TraversedLink).InvalidURLCode)
+
+	f := NewFactory()
+	f.AllowRedirectsWithoutLocation = true
+	hr, err = f.CheckLink(context.Background(), server.URL)
+	suite.Nil(err, "No error expected")
+	suite.True(hr.IsURLValid, "The redirect should be accepted when allowed")
+}
+
+func (suite *LinkSuite) TestRedirectHopsRecorded() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.SetCookie(w, &http.Cookie{Name: "tracker", Value: "abc"})
+			http.Redirect(w, r, "/dest", http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	f := NewFactory()
+	hr, err := f.CheckLink(context.Background(), server.URL+"/short")
+	suite.Nil(err, "No error expected")
+	suite.Nil(hr.RedirectHops, "Hops should only be recorded when requested")
+
+	f.RecordRedirectHops = true
+	hr, err = f.CheckLink(context.Background(), server.URL+"/short")
+	suite.Nil(err, "No error expected")
+	suite.Equal([]HTTPRedirectHop{{URL: server.URL + "/short", StatusCode: http.StatusMovedPermanently, Location: "/dest", SetCookies: []string{"tracker=abc"}}}, hr.RedirectHops)
+
+	_, link, err := f.TraverseLink(context.Background(), server.URL+"/short")
+	suite.Nil(err, "No error expected")
+	suite.Equal(hr.RedirectHops, link.(*TraversedLink).RedirectHops, "TraverseLink should record the same hops")
+}
+
+func (suite *LinkSuite) TestRedirectChainCollapsed() {
+	var hops []*url.URL
+	for _, hop := range []string{"http://bit.ly/abc", "https://Netspective.com/solutions", "https://netspective.com/solutions/", "https://www.netspective.com/solutions/"} {
+		u, _ := url.Parse(hop)
+		hops = append(hops, u)
+	}
+	collapsed := collapseRedirectChain(hops)
+	suite.Len(collapsed, 3, "Hops differing only by case or trailing slash should be collapsed")
+	suite.Equal("https://www.netspective.com/solutions/", collapsed[2].String())
+
+	suite.Nil(collapseRedirectChain(hops[1:3]), "A chain without meaningful destination changes should be empty")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/dest", http.StatusMovedPermanently)
+		case "/dest":
+			http.Redirect(w, r, "/dest/", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/short")
+	suite.Nil(err, "No error expected")
+	chain := link.(*TraversedLink).RedirectChain
+	suite.Require().Len(chain, 2, "The trailing slash hop should be collapsed by TraverseLink")
+	suite.Equal(server.URL+"/short", chain[0].String())
+	suite.Equal(server.URL+"/dest", chain[1].String())
+}
+
+func (suite *LinkSuite) TestMultipleContentTypes() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/octet-stream")
+		w.Header().Add("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>Doubled</title></head></html>"))
+	}))
+	defer server.Close()
+
+	ctx, trace := withHTTPTrace(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := suite.factory.httpClient.Do(req.WithContext(ctx))
+	suite.Nil(err, "No error expected")
+	resp.Body.Close()
+	suite.Equal([]string{"text/html; charset=utf-8"}, resp.Header["Content-Type"], "The recognized media type should be preferred")
+	suite.Equal([]string{"application/octet-stream", "text/html; charset=utf-8"}, trace.contentTypes)
+	suite.Equal("text/html; charset=utf-8", trace.contentType)
+
+	_, link, _ := suite.factory.TraverseLink(context.Background(), server.URL)
+	hr := link.(*TraversedLink)
+	suite.True(hr.IsHTML(), "Content should be inspected as HTML")
+	suite.Require().Len(hr.Issues, 1)
+	suite.Equal("LECTIOLINK-010-MULTIPLECONTENTTYPES", hr.Issues[0].Code)
+
+	suite.Equal("application/octet-stream", chooseContentType([]string{"not a type;;", "application/octet-stream"}))
+	suite.Equal("not a type;;", chooseContentType([]string{"not a type;;", "also bad;;"}))
+}
+
+func (suite *LinkSuite) TestAcceptHeader() {
+	var accepted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = append(accepted, r.Header.Get("Accept"))
+	}))
+	defer server.Close()
+
+	get := func(ctx context.Context, accept string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if len(accept) > 0 {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := suite.factory.httpClient.Do(req.WithContext(ctx))
+		suite.Nil(err, "No error expected")
+		resp.Body.Close()
+	}
+
+	ctx := context.Background()
+	get(ctx, "")
+	get(withPolicyOverrides(ctx, []interface{}{Accept("application/json")}), "")
+	get(ctx, "image/png")
+	suite.Equal([]string{DefaultAcceptHeader, "application/json", "image/png"}, accepted)
+
+	accepted = nil
+	suite.factory.TraverseLink(ctx, server.URL)
+	suite.factory.TraverseLink(ctx, server.URL, Accept("application/json"))
+	suite.Equal([]string{DefaultAcceptHeader, "application/json"}, accepted, "TraverseLink should send the Accept header")
+}
+
+func (suite *LinkSuite) TestInMemoryMetrics() {
+	metrics := NewInMemoryMetrics()
+	f := NewFactory(metrics)
+	suite.Equal(metrics, f.Metrics, "The collector should be picked up from the options")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	resp, err := f.httpClient.Get(server.URL)
+	suite.Nil(err, "No error expected")
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Equal(int64(10), metrics.BytesDownloaded())
+
+	_, _, err = f.TraverseLink(context.Background(), server.URL)
+	suite.Nil(err, "No error expected")
+	suite.Equal(int64(1), metrics.Traversed(TraversalOutcomeTraversed))
+	suite.Equal(int64(20), metrics.BytesDownloaded(), "Bytes read by TraverseLink should be counted")
+
+	_, link, _ := f.TraverseLink(context.Background(), "ftp://example.com/file")
+	suite.Equal(TraversalOutcomeInvalid, traversalOutcome(link))
+	suite.Equal(int64(1), metrics.Traversed(TraversalOutcomeInvalid))
+	suite.Equal(int64(1), metrics.Traversed(TraversalOutcomeTraversed))
+	observations, _ := metrics.Durations()
+	suite.Equal(int64(2), observations)
+
+	suite.Equal(TraversalOutcomeIgnored, traversalOutcome(&TraversedLink{IsURLValid: true, IsURLIgnored: true}))
+	suite.Equal(TraversalOutcomeTraversed, traversalOutcome(&TraversedLink{IsURLValid: true}))
+	suite.Nil(suite.factory.Metrics, "Metrics are off unless a collector is given")
+}
+
+func (suite *LinkSuite) TestInvalidContentIssues() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset")
+		w.Write([]byte("<html><head><title>Bad media type</title></head></html>"))
+	}))
+	defer server.Close()
+
+	_, link, _ := suite.factory.TraverseLink(context.Background(), server.URL)
+	hr := link.(*TraversedLink)
+	suite.True(hr.IsURLValid, "URL should be valid")
+	suite.False(hr.Content.IsValid(), "A malformed media type should make the content invalid")
+
+	suite.Equal(http.StatusOK, hr.HTTPStatusCode)
+
+	var warnings []string
+	suite.True(hr.Traversable(func(code, message string) { warnings = append(warnings, code+" "+message) }), "Issues should not affect traversability")
+	suite.Require().Len(warnings, 1, "The invalid content should be collectable as an Issue")
+	suite.Contains(warnings[0], "LECTIOLINK-014-INVALIDCONTENT")
+	suite.Contains(warnings[0], "mime: invalid media parameter", "The resource error should explain the issue")
+}
+
+// failingCreator is a resource.FileAttachmentCreator which can't create files; it may also stop on the resulting error
+type failingCreator struct {
+	stop bool
+}
+
+func (c failingCreator) CreateFile(ctx context.Context, url *url.URL, t resource.Type) (afero.Fs, afero.File, error) {
+	return nil, nil, xerrors.New("disk full")
+}
+
+func (c failingCreator) AutoAssignExtension(ctx context.Context, url *url.URL, t resource.Type) bool {
+	return true
+}
+
+func (c failingCreator) StopOnDownloadError(ctx context.Context, url *url.URL, t resource.Type, err error) bool {
+	return c.stop
+}
+
+func (suite *LinkSuite) TestDownloadErrorIssues() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4\n%%EOF\n"))
+	}))
+	defer server.Close()
+
+	traversable, link, err := NewFactory(failingCreator{}).TraverseLink(context.Background(), server.URL+"/paper.pdf")
+	suite.Nil(err, "A failed download should not make the link invalid")
+	suite.True(traversable, "A failed download should not make the link invalid")
+	hr := link.(*TraversedLink)
+	suite.False(hr.WasDownloaded(), "Nothing should be downloaded")
+	suite.Require().Len(hr.Issues, 1, "The failed download should be reported")
+	suite.Equal("LECTIOLINK-015-INVALIDATTACHMENT", hr.Issues[0].Code)
+	suite.Contains(hr.Issues[0].Message, "disk full", "The download error should explain the issue")
+
+	traversable, link, err = NewFactory(failingCreator{stop: true}).TraverseLink(context.Background(), server.URL+"/paper.pdf")
+	suite.NotNil(err, "The caller's policy should still be able to stop on download errors")
+	suite.False(traversable, "A stopped download should not be traversable")
+	hr = link.(*TraversedLink)
+	suite.Equal("LECTIOLINK-015-INVALIDATTACHMENT", hr.InvalidURLCode)
+	suite.Require().Len(hr.Issues, 1, "The stopped download should also be reported")
+	suite.Equal("LECTIOLINK-015-INVALIDATTACHMENT", hr.Issues[0].Code)
+}
+
+func (suite *LinkSuite) TestMediaTypeParams() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/declared" {
+			w.Header().Set("Content-Type", `text/html; Charset="ISO-8859-1"; version=5`)
+		} else {
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/declared")
+	suite.Nil(err, "No error expected")
+	hr := link.(*TraversedLink)
+	suite.Equal("ISO-8859-1", hr.Charset())
+	version, ok := hr.MediaTypeParam("Version")
+	suite.True(ok, "Param names should be case-insensitive")
+	suite.Equal("5", version)
+	_, ok = hr.MediaTypeParam("boundary")
+	suite.False(ok, "Missing params should not be found")
+
+	_, link, err = suite.factory.TraverseLink(context.Background(), server.URL+"/undeclared")
+	suite.Nil(err, "No error expected")
+	suite.Equal("", link.(*TraversedLink).Charset(), "Charset should be empty when it isn't declared")
+	suite.Equal("", new(TraversedLink).Charset(), "Charset should be empty without content")
+}
+
+func (suite *LinkSuite) TestSniffedContentType() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/declared" {
+			w.Header().Set("Content-Type", "text/html")
+		} else {
+			w.Header()["Content-Type"] = nil // stop the server from sniffing it
+		}
+		w.Write([]byte(`<html><head><meta property="og:title" content="Undeclared"></head></html>`))
+	}))
+	defer server.Close()
+
+	traversable, link, err := suite.factory.TraverseLink(context.Background(), server.URL)
+	suite.Nil(err, "No error expected")
+	suite.True(traversable, "URL should be traversable")
+	hr := link.(*TraversedLink)
+	suite.True(hr.ContentTypeSniffed, "The media type should be recorded as sniffed")
+	suite.True(hr.IsHTML(), "HTML without a Content-Type should be detected")
+	suite.Equal("Undeclared", hr.DisplayTitle(), "The sniffed bytes should still be parsed")
+
+	_, link, err = suite.factory.TraverseLink(context.Background(), server.URL+"/declared")
+	suite.Nil(err, "No error expected")
+	suite.False(link.(*TraversedLink).ContentTypeSniffed, "Declared media types should not be sniffed")
+}
+
+func (suite *LinkSuite) TestDeclaredLength() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", "26")
+		}
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/page")
+	suite.Nil(err, "No error expected")
+	suite.Equal(int64(26), link.(*TraversedLink).DeclaredLength)
+
+	_, link, err = suite.factory.TraverseLink(context.Background(), server.URL+"/chunked")
+	suite.Nil(err, "No error expected")
+	suite.Equal(int64(-1), link.(*TraversedLink).DeclaredLength, "Chunked responses have no declared length")
+
+	checked, err := suite.factory.CheckLink(context.Background(), server.URL+"/page")
+	suite.Nil(err, "No error expected")
+	suite.Equal(int64(26), checked.DeclaredLength, "The length should be known without downloading")
+}
+
+func (suite *LinkSuite) TestDispositionType() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/attachment":
+			w.Header().Set("Content-Disposition", `Attachment; filename="page.html"`)
+		case "/malformed":
+			w.Header().Set("Content-Disposition", "inline; filename")
+		}
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	for path, expected := range map[string]string{"/attachment": "attachment", "/malformed": "inline", "/none": ""} {
+		_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+path)
+		suite.Nil(err, "No error expected for %s", path)
+		suite.Equal(expected, link.(*TraversedLink).DispositionType(), "Unexpected disposition type for %s", path)
+	}
+}
+
+func (suite *LinkSuite) TestPreRequestURLRewriter() {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RawQuery
+		fmt.Fprint(w, r.URL.RawQuery)
+	}))
+	defer server.Close()
+
+	suite.factory.PreRequestURLRewriter = func(ctx context.Context, u *url.URL) *url.URL {
+		rewritten := *u
+		rewritten.RawQuery = "apiKey=secret"
+		return &rewritten
+	}
+	defer func() { suite.factory.PreRequestURLRewriter = nil }()
+
+	resp, err := suite.factory.httpClient.Get(server.URL + "/page")
+	suite.Nil(err, "No error expected")
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Equal("apiKey=secret", string(body), "The rewritten URL should be requested")
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/page?utm_source=test")
+	suite.Nil(err, "No error expected")
+	suite.Equal("apiKey=secret", requested, "The rewritten URL should be requested by TraverseLink")
+	suite.Equal(server.URL+"/page?utm_source=test", link.(*TraversedLink).OrigURLText, "The original URL should still be recorded")
+	suite.Equal(server.URL+"/page?utm_source=test", link.(*TraversedLink).ResolvedURL.String(), "The rewritten URL should not be resolved")
+	suite.Equal(server.URL+"/page", link.(*TraversedLink).FinalizedURL.String(), "The original URL should be cleaned")
+}
+
+func (suite *LinkSuite) TestTLSInfo() {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	factory := NewFactory(suite, server.Client())
+
+	ctx, trace := withHTTPTrace(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := factory.httpClient.Do(req.WithContext(ctx))
+	suite.Nil(err, "No error expected")
+	resp.Body.Close()
+
+	info := newTLSInfo(trace.tls)
+	suite.NotNil(info, "HTTPS responses should have TLS info")
+	suite.True(info.Verified, "The test server's certificate is trusted by its client")
+	suite.True(info.NotAfter.After(time.Now()), "The test server's certificate should not be expired")
+	suite.Contains(info.Issuer, "Acme Co")
+
+	_, link, err := factory.TraverseLink(context.Background(), server.URL)
+	suite.Nil(err, "No error expected")
+	suite.Require().NotNil(link.(*TraversedLink).TLSInfo, "TraverseLink should record the TLS info")
+	suite.True(link.(*TraversedLink).TLSInfo.Verified, "The test server's certificate is trusted by its client")
+
+	factory.SkipTLSInfo = true
+	_, link, _ = factory.TraverseLink(context.Background(), server.URL)
+	suite.Nil(link.(*TraversedLink).TLSInfo, "TLS info should not be kept when skipped")
+
+	suite.Nil(newTLSInfo(nil), "Plain HTTP responses have no TLS info")
+}
+
+func (suite *LinkSuite) TestTLSExpiryWarning() {
+	hr := &TraversedLink{OrigURLText: "https://www.netspective.com", TLSInfo: &TLSInfo{Issuer: "CN=Test CA", NotAfter: time.Now().Add(7 * 24 * time.Hour)}}
+	suite.factory.checkTLSExpiry(hr)
+	suite.Len(hr.Issues, 0, "The check should be off by default")
+
+	suite.factory.WarnTLSExpiryWithin = 14 * 24 * time.Hour
+	defer func() { suite.factory.WarnTLSExpiryWithin = 0 }()
+	suite.factory.checkTLSExpiry(hr)
+	suite.Len(hr.Issues, 1, "A certificate expiring within the window should be flagged")
+	suite.Equal("LECTIOLINK-007-TLSEXPIRING", hr.Issues[0].Code)
+
+	hr = &TraversedLink{TLSInfo: &TLSInfo{NotAfter: time.Now().Add(90 * 24 * time.Hour)}}
+	suite.factory.checkTLSExpiry(hr)
+	suite.Len(hr.Issues, 0, "A certificate expiring outside the window should not be flagged")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	factory := NewFactory(suite, server.Client())
+	factory.WarnTLSExpiryWithin = time.Until(server.Certificate().NotAfter) + time.Hour
+	_, link, err := factory.TraverseLink(context.Background(), server.URL)
+	suite.Nil(err, "No error expected")
+	suite.Require().Len(link.(*TraversedLink).Issues, 1, "TraverseLink should flag the expiring certificate")
+	suite.Equal("LECTIOLINK-007-TLSEXPIRING", link.(*TraversedLink).Issues[0].Code)
+}
+
+func (suite *LinkSuite) TestCheckConnectivity() {
+	ctx := context.Background()
+	suite.Nil(suite.factory.CheckConnectivity(ctx, "data:,ok"), "Local probe should always succeed")
+
+	err := suite.factory.CheckConnectivity(ctx, "https://invalid.invalid/")
+	suite.NotNil(err, "Probe of an unresolvable host should fail")
+	suite.Contains(err.Error(), "LECTIOLINK-003-DNSERROR")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/proxy-auth":
+			w.WriteHeader(http.StatusProxyAuthRequired)
+		case "/bad-gateway":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	err = suite.factory.CheckConnectivity(ctx, server.URL+"/proxy-auth")
+	suite.NotNil(err, "A proxy refusing the request should fail the probe")
+	suite.Contains(err.Error(), "407 Proxy Authentication Required")
+	err = suite.factory.CheckConnectivity(ctx, server.URL+"/bad-gateway")
+	suite.NotNil(err, "A failing gateway should fail the probe")
+	suite.Contains(err.Error(), "502 Bad Gateway")
+	suite.Nil(suite.factory.CheckConnectivity(ctx, server.URL+"/not-found"), "Any other response proves connectivity")
+}
+
+func (suite *LinkSuite) TestCheckLink() {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path+" "+r.Header.Get("Range"))
+		switch {
+		case r.URL.Path == "/start":
+			http.Redirect(w, r, "/no-head", http.StatusFound)
+		case r.URL.Path == "/no-head" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/no-head":
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("<"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	hr, err := suite.factory.CheckLink(context.Background(), server.URL+"/start")
+	suite.Nil(err, "No error expected")
+	suite.True(hr.IsURLValid, "URL should be valid")
+	suite.Nil(hr.Content, "The body should not be inspected")
+	suite.Equal(http.StatusPartialContent, hr.HTTPStatusCode)
+	suite.Equal(1, hr.HTTPRedirectCount, "Only the redirect of the GET fallback should be counted")
+	suite.Equal(server.URL+"/no-head", hr.ResolvedURL.String())
+	suite.Equal([]string{"HEAD /start ", "HEAD /no-head ", "GET /start bytes=0-0", "GET /no-head bytes=0-0"}, methods)
+
+	hr, err = suite.factory.CheckLink(context.Background(), server.URL+"/missing")
+	suite.Nil(err, "HTTP errors are reported on the link, not as errors")
+	suite.False(hr.IsURLValid, "URL should not be valid")
+	suite.Equal("LECTIOLINK-009-HTTPERROR", hr.InvalidURLCode)
+	suite.Equal(http.StatusNotFound, hr.HTTPStatusCode)
+}
+
 func (suite *LinkSuite) TestInvalidlyFormattedURLs() {
 	hr := suite.traverseSingleURLFromMockTweet("Test an invalidly formatted URL %s in a mock tweet", "https://t")
 	suite.False(hr.IsURLValid, "URL should have invalid format")
 	suite.Nil(hr.Content, "No content should be available")
+	suite.Nil(hr.TwitterCard(), "No content means no Twitter Card")
+	suite.False(hr.IsHTML(), "No content means not HTML")
+	suite.False(hr.WasDownloaded(), "No content means nothing was downloaded")
 
 	finalURL, finalURLErr := hr.FinalURL()
 	suite.Nil(finalURL, "Ensure FinalURL is nil")
 	suite.Nil(finalURLErr, "Ensure error is nil")
 }
 
+func (suite *LinkSuite) TestDisallowedSchemes() {
+	for _, urlText := range []string{"ftp://ftp.netspective.com/file.txt", "javascript:alert(1)", "www.netspective.com", "file:///etc/passwd", "data:,Hello"} {
+		hr := suite.traverseSingleURLFromMockTweet("Test a URL %s with a disallowed scheme", urlText)
+		suite.False(hr.IsURLValid, "URL %s should be invalid", urlText)
+		suite.Equal("LECTIOLINK-008-SCHEMENOTALLOWED", hr.InvalidURLCode, "URL %s should be rejected by scheme", urlText)
+		suite.Nil(hr.Content, "No request should be made for %s", urlText)
+	}
+}
+
+func (suite *LinkSuite) TestDisallowedSchemesInRedirects() {
+	fixture, _ := ioutil.TempFile("", "link-fixture-*.html")
+	defer os.Remove(fixture.Name())
+	fixture.WriteString(`<html><head><meta property="og:title" content="Local"></head></html>`)
+	fixture.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data":
+			http.Redirect(w, r, "data:text/html,%3Cmeta%20property%3D%22og%3Atitle%22%20content%3D%22Injected%22%3E", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file://"+fixture.Name(), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	factory := NewFactory(suite)
+	factory.AllowFileScheme = true
+	for _, path := range []string{"/data", "/file"} {
+		traversable, link, err := factory.TraverseLink(context.Background(), server.URL+path)
+		suite.NotNil(err, "Redirect from %s should be rejected", path)
+		suite.False(traversable, "Redirect from %s should not be traversable", path)
+		hr := link.(*TraversedLink)
+		suite.Equal("LECTIOLINK-008-SCHEMENOTALLOWED", hr.InvalidURLCode, "Redirect from %s should be rejected by scheme", path)
+		suite.Nil(hr.Content, "Redirect target of %s should not be read", path)
+	}
+}
+
 func (suite *LinkSuite) TestInvalidDestinationURLs() {
 	hr := suite.traverseSingleURLFromMockTweet("Test a validly formatted URL %s but with invalid destination in a mock tweet", "https://t.co/fDxPF")
 	suite.False(hr.IsURLValid, "URL should be considered invalid")
@@ -76,6 +834,112 @@ func (suite *LinkSuite) TestInvalidDestinationURLs() {
 	suite.Nil(finalURLErr, "Ensure error is nil")
 }
 
+func (suite *LinkSuite) TestInvalidURLCodes() {
+	code, _ := invalidURLCode(xerrors.Errorf("Unable to create page from URL: %w", &net.DNSError{Err: "no such host", Name: "invalid.example", IsNotFound: true}))
+	suite.Equal("LECTIOLINK-003-DNSERROR", code)
+
+	code, _ = invalidURLCode(xerrors.Errorf("Unable to create page from URL: %w", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}))
+	suite.Equal("LECTIOLINK-003-DNSERROR", code, "DNS errors take precedence over timeouts")
+
+	code, _ = invalidURLCode(xerrors.Errorf("Unable to create page from URL: %w", &url.Error{Op: "Get", URL: "https://example.com", Err: timeoutError{}}))
+	suite.Equal("LECTIOLINK-004-TIMEOUT", code)
+
+	code, _ = invalidURLCode(xerrors.Errorf("Unable to create page from URL: %w", x509.UnknownAuthorityError{}))
+	suite.Equal("LECTIOLINK-005-TLSERROR", code)
+
+	code, _ = invalidURLCode(xerrors.New("something else"))
+	suite.Equal("LECTIOLINK-001-INVALIDURL", code)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (suite *LinkSuite) TestTraverseLinkStream() {
+	ctx := context.Background()
+	suite.followHTMLRedirects = false
+	in := make(chan string)
+	go func() {
+		for _, urlText := range []string{"https://t", "https://t.co/fDxPF", "https://t.co/xNzrxkHE1u"} {
+			in <- urlText
+		}
+		close(in)
+	}()
+
+	results := make(map[string]TraversalResult)
+	for result := range suite.factory.TraverseLinkStream(ctx, in, 2) {
+		results[result.OrigURLText] = result
+	}
+	suite.Len(results, 3, "There should be one result per input URL")
+	suite.False(results["https://t"].Traversable, "Invalid URL should not be traversable")
+	suite.NotNil(results["https://t"].Error, "Invalid URL should report its error")
+	suite.True(results["https://t.co/xNzrxkHE1u"].Link.(*TraversedLink).IsURLIgnored, "URL should be ignored (skipped)")
+}
+
+func (suite *LinkSuite) TestTraverseUniqueLinkStream() {
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/final?utm_source=a", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><head></head></html>"))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	in := make(chan string)
+	go func() {
+		for _, urlText := range []string{server.URL + "/a", server.URL + "/a", server.URL + "/b"} {
+			in <- urlText
+		}
+		close(in)
+	}()
+
+	var links []Link
+	for result := range suite.factory.TraverseUniqueLinkStream(ctx, in, 2) {
+		suite.Nil(result.Error, "No error expected")
+		links = append(links, result.Link)
+	}
+	suite.Len(links, 3, "There should be one result per input URL")
+	suite.True(links[0] == links[1] && links[1] == links[2], "Inputs finalizing to the same URL should share one link")
+	suite.Equal(1, requests["/a"], "Duplicate inputs should be requested once")
+	suite.Equal(1, requests["/b"], "Each distinct input should be requested once")
+}
+
+func (suite *LinkSuite) TestURLExtractors() {
+	extractor := NewURLExtractor()
+	suite.Equal([]string{"https://t.co/xNzrxkHE1u", "http://bit.ly/lectio_harvester_resource_test01"},
+		extractor.Extract("Check out https://t.co/xNzrxkHE1u. Also (http://bit.ly/lectio_harvester_resource_test01)"))
+
+	var markdown URLExtractor = markdownURLExtractor{}
+	suite.Equal([]string{"https://www.netspective.com"}, markdown.Extract("See [Netspective](https://www.netspective.com) for details"))
+
+	results := suite.factory.TraverseText(context.Background(), "See https://t.co/xNzrxkHE1u but no markdown links", markdown)
+	suite.Len(results, 0, "The supplied extractor should be used instead of the default")
+}
+
+// markdownURLExtractor finds only the destinations of markdown links
+type markdownURLExtractor struct{}
+
+func (markdownURLExtractor) Extract(text string) []string {
+	var urls []string
+	for _, match := range regexp.MustCompile(`\]\((https?://[^)\s]+)\)`).FindAllStringSubmatch(text, -1) {
+		urls = append(urls, match[1])
+	}
+	return urls
+}
+
 func (suite *LinkSuite) TestSimplifiedHostnames() {
 	url, _ := url.Parse("https://www.netspective.com")
 	suite.Equal("netspective.com", GetSimplifiedHostname(url))
@@ -85,6 +949,344 @@ func (suite *LinkSuite) TestSimplifiedHostnames() {
 	suite.Equal("news.healthcareguys", GetSimplifiedHostnameWithoutTLD(url))
 }
 
+func (suite *LinkSuite) TestNormalizedURLs() {
+	url, _ := url.Parse("HTTP://WWW.Netspective.COM/Solutions/OpsFolio/?ID=Mixed")
+	suite.Equal("http://www.netspective.com/Solutions/OpsFolio/?ID=Mixed", normalizeURL(url).String(), "Only scheme and host should be lowercased")
+	suite.Equal("WWW.Netspective.COM", url.Host, "The original URL should not be modified")
+}
+
+func (suite *LinkSuite) TestInternationalHostnames() {
+	url, _ := url.Parse("http://München.de/Straße?q=1")
+	suite.Equal("http://xn--mnchen-3ya.de/Stra%C3%9Fe?q=1", normalizeURL(url).String())
+	url, _ = url.Parse("https://例え.テスト:8443/")
+	suite.Equal("https://xn--r8jz45g.xn--zckzah:8443/", normalizeURL(url).String())
+
+	url, _ = url.Parse("http://xn--mnchen-3ya.de/")
+	suite.Equal("münchen.de", GetUnicodeHostname(url))
+	suite.Equal(url.String(), normalizeURL(url).String(), "ASCII hostnames should be unchanged")
+}
+
+func (suite *LinkSuite) TestWasShortened() {
+	finalURL, _ := url.Parse("https://www.netspective.com/solutions/opsfolio/")
+	hr := &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test02", FinalizedURL: finalURL, HTTPRedirectCount: 1}
+	suite.True(hr.WasShortened(), "bit.ly redirecting to netspective.com should be considered shortened")
+	suite.Equal("bit.ly", hr.ShortenerHost())
+
+	hr = &TraversedLink{OrigURLText: "http://netspective.com/solutions/opsfolio", FinalizedURL: finalURL, HTTPRedirectCount: 1}
+	suite.False(hr.WasShortened(), "Redirecting within the same registrable domain is not shortening")
+	suite.Equal("", hr.ShortenerHost())
+
+	hr = &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test02", FinalizedURL: finalURL}
+	suite.False(hr.WasShortened(), "A link which wasn't redirected was not shortened")
+
+	hr = &TraversedLink{OrigURLText: "https://www.netspective.com/solutions/opsfolio/", FinalizedURL: finalURL,
+		OrigLink: &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test02"}}
+	suite.Equal("bit.ly", hr.ShortenerHost(), "HTML redirects should count as redirects")
+
+	hr = &TraversedLink{OrigURLText: "https://www.google.com/url?q=http://bit.ly/lectio_harvester_resource_test02",
+		UnwrappedURLText: "http://bit.ly/lectio_harvester_resource_test02", FinalizedURL: finalURL, HTTPRedirectCount: 1}
+	suite.Equal("bit.ly", hr.ShortenerHost(), "The unwrapped URL should be compared")
+}
+
+func (suite *LinkSuite) TestTags() {
+	suite.Equal([]string{"Go", "Link Curation", "metadata", "harvesting"}, mergeTags([]string{"Go", "Link Curation"}, []string{" metadata, go ,, harvesting"}))
+	suite.Equal([]string{}, mergeTags(nil, nil), "No tags should be an empty slice")
+	suite.Equal([]string{}, (&TraversedLink{}).Tags(), "A link without content has no tags")
+
+	hr := &TraversedLink{Content: &resource.Page{PageType: resource.PageType{MedType: "text/html"}, HTMLParsed: true,
+		MetaPropertyTags: map[string]interface{}{"article:tag": "Go", "keywords": "go, links"}}}
+	suite.Equal([]string{"Go", "links"}, hr.Tags())
+}
+
+func (suite *LinkSuite) TestURLDisplayTitles() {
+	finalURL, _ := url.Parse("https://www.netspective.com/blog/opsfolio_launch-announcement.html")
+	hr := &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test01", FinalizedURL: finalURL}
+	suite.Equal("Opsfolio Launch Announcement", hr.DisplayTitle(), "Without content the title should come from the final URL's path")
+
+	hr = &TraversedLink{OrigURLText: "https://www.netspective.com/"}
+	suite.Equal("netspective.com", hr.DisplayTitle(), "Without a path the title should be the hostname")
+
+	hr = &TraversedLink{OrigURLText: "https://t"}
+	suite.Equal("t", hr.DisplayTitle())
+}
+
+func (suite *LinkSuite) TestDisplayTitleFallbacks() {
+	finalURL, _ := url.Parse("https://www.netspective.com/blog/opsfolio-launch.html")
+	page := func(tags map[string]interface{}) *resource.Page {
+		return &resource.Page{TargetURL: finalURL, PageType: resource.PageType{MedType: "text/html"}, HTMLParsed: true, MetaPropertyTags: tags}
+	}
+
+	hr := &TraversedLink{FinalizedURL: finalURL, Content: page(map[string]interface{}{"og:title": "Open Graph", "twitter:title": "Twitter"})}
+	suite.Equal("Open Graph", hr.DisplayTitle(), "og:title should be preferred")
+
+	hr.Content = page(map[string]interface{}{"og:title": " ", "twitter:title": "Twitter"})
+	suite.Equal("Twitter", hr.DisplayTitle(), "twitter:title should be used without og:title")
+
+	hr.Content = page(map[string]interface{}{})
+	suite.Equal("Opsfolio Launch", hr.DisplayTitle(), "The URL should be used without title meta tags")
+
+	hr.Content = &resource.Page{TargetURL: finalURL}
+	suite.Equal("Opsfolio Launch", hr.DisplayTitle(), "Content without a type should use the URL")
+	suite.False(hr.IsHTML(), "Content without a type is not HTML")
+
+	hr.Content = page(map[string]interface{}{"og:title": "Open Graph"})
+	hr.IsURLIgnored = true
+	suite.Equal("Opsfolio Launch", hr.DisplayTitle(), "Ignored links should use the URL")
+}
+
+func (suite *LinkSuite) TestWalkRedirects() {
+	parse := func(text string) *url.URL {
+		u, _ := url.Parse(text)
+		return u
+	}
+
+	refresher := &TraversedLink{
+		OrigURLText:   "http://t.co/abc",
+		RedirectChain: []*url.URL{parse("http://t.co/abc"), parse("https://example.com/refresh")},
+		ResolvedURL:   parse("https://example.com/refresh"),
+	}
+	final := &TraversedLink{
+		OrigURLText:   "https://example.com/target",
+		OrigLink:      refresher,
+		RedirectChain: []*url.URL{parse("https://example.com/target"), parse("https://www.example.com/target")},
+		ResolvedURL:   parse("https://www.example.com/target"),
+	}
+
+	var hops []string
+	final.WalkRedirects(func(hop int, from, to *url.URL, kind RedirectKind) {
+		hops = append(hops, fmt.Sprintf("%d %s %s -> %s", hop, kind, from, to))
+	})
+	suite.Equal([]string{
+		"1 http http://t.co/abc -> https://example.com/refresh",
+		"2 html https://example.com/refresh -> https://example.com/target",
+		"3 http https://example.com/target -> https://www.example.com/target",
+	}, hops)
+
+	called := false
+	(&TraversedLink{OrigURLText: "https://example.com"}).WalkRedirects(func(int, *url.URL, *url.URL, RedirectKind) { called = true })
+	suite.False(called, "WalkRedirects should be a no-op without redirects")
+}
+
+func (suite *LinkSuite) TestIgnoreLinkRule() {
+	ctx := context.Background()
+	ignoredURL, _ := url.Parse("https://twitter.com/Live5News/status/993220120402161664/photo/1")
+	ignore, reason, rule := suite.factory.IgnoreLinkRule(ctx, ignoredURL)
+	suite.True(ignore, "URL should be ignored")
+	suite.Contains(reason, rule.String(), "The reason should name the matched rule")
+	suite.Contains(suite.factory.IgnoreURLsRegExprs, rule, "The matched rule should be one of the factory's rules")
+
+	keptURL, _ := url.Parse("https://www.netspective.com/")
+	ignore, _, rule = suite.factory.IgnoreLinkRule(ctx, keptURL)
+	suite.False(ignore, "URL should not be ignored")
+	suite.Nil(rule)
+}
+
+func (suite *LinkSuite) TestReportAllIgnoreRules() {
+	ctx := context.Background()
+	resolvedURL, _ := url.Parse("https://twitter.com/Live5News/status/993220120402161664?ref=https://t.co")
+	hr := &TraversedLink{ResolvedURL: resolvedURL}
+	suite.factory.reportIgnoreRules(ctx, hr)
+	suite.Len(hr.Issues, 0, "Rules are only reported when requested")
+
+	suite.factory.ReportAllIgnoreRules = true
+	defer func() { suite.factory.ReportAllIgnoreRules = false }()
+	suite.factory.reportIgnoreRules(ctx, hr)
+	suite.Len(hr.Issues, 2, "Both the twitter status and t.co rules should match")
+	suite.Equal("LECTIOLINK-013-IGNORERULEMATCHED", hr.Issues[0].Code)
+	suite.Equal("Matched Ignore Rule `^https://twitter.com/(.*?)/status/(.*)$`", hr.Issues[0].Message)
+	suite.Equal("Matched Ignore Rule `https://t.co`", hr.Issues[1].Message)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	factory := NewFactory()
+	factory.IgnoreURLsRegExprs = []*regexp.Regexp{regexp.MustCompile(`/ignored$`), regexp.MustCompile(`^http://127\.0\.0\.1`)}
+	factory.ReportAllIgnoreRules = true
+	_, link, err := factory.TraverseLink(ctx, server.URL+"/ignored")
+	suite.Nil(err, "No error expected")
+
+	var warnings []string
+	suite.False(link.Traversable(func(code, message string) { warnings = append(warnings, code) }), "The link should be ignored")
+	suite.Equal([]string{"LECTIOLINK-002-URLIGNORED", "LECTIOLINK-013-IGNORERULEMATCHED", "LECTIOLINK-013-IGNORERULEMATCHED"}, warnings,
+		"The matched rules of an ignored link should be reported after the reason it was ignored")
+}
+
+func (suite *LinkSuite) TestPreview() {
+	ctx := context.Background()
+	normalized, ignored, reason, cleanedParams, err := suite.factory.Preview(ctx, "https://WWW.Netspective.com/page?id=1&utm_source=test")
+	suite.Nil(err, "No error expected")
+	suite.False(ignored, "URL should not be ignored")
+	suite.Empty(reason)
+	suite.Equal("https://www.netspective.com/page?id=1", normalized.String())
+	suite.Len(cleanedParams, 1)
+	suite.Equal("utm_source", cleanedParams[0].ParamName)
+
+	normalized, ignored, reason, cleanedParams, err = suite.factory.Preview(ctx, "https://twitter.com/Live5News/status/993220120402161664?utm_source=test")
+	suite.Nil(err, "No error expected")
+	suite.True(ignored, "URL should be ignored")
+	suite.Equal("Matched Ignore Rule `^https://twitter.com/(.*?)/status/(.*)$`", reason)
+	suite.Nil(cleanedParams, "Ignored URLs aren't cleaned")
+	suite.Equal("https://twitter.com/Live5News/status/993220120402161664?utm_source=test", normalized.String())
+
+	_, _, _, _, err = suite.factory.Preview(ctx, "ftp://example.com/file")
+	suite.NotNil(err, "Disallowed schemes should be reported")
+}
+
+func (suite *LinkSuite) TestExport() {
+	finalURL, _ := url.Parse("https://www.netspective.com/solutions/opsfolio/")
+	hr := &TraversedLink{
+		TraversedOn:    time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC),
+		OrigURLText:    "http://bit.ly/lectio_harvester_resource_test02",
+		IsURLValid:     true,
+		HTTPStatusCode: http.StatusOK,
+		ResolvedURL:    finalURL,
+		FinalizedURL:   finalURL,
+	}
+
+	export := hr.Export()
+	suite.Equal(LinkExportVersion, export.Version)
+	suite.Equal("2019-05-01T12:00:00Z", export.TraversedOn)
+	suite.Equal("https://www.netspective.com/solutions/opsfolio/", export.FinalURL)
+	suite.Empty(export.CleanedURL, "Nothing was cleaned")
+	suite.Equal("Opsfolio", export.Title)
+	suite.Empty(export.MediaType, "Without content the media type is unknown")
+
+	encoded, err := json.Marshal(export)
+	suite.Nil(err, "No error expected")
+	var decoded map[string]interface{}
+	suite.Nil(json.Unmarshal(encoded, &decoded))
+	suite.Equal(float64(LinkExportVersion), decoded["version"])
+	suite.Equal("http://bit.ly/lectio_harvester_resource_test02", decoded["origURL"])
+	suite.Equal(float64(http.StatusOK), decoded["status"])
+	suite.Equal([]interface{}{}, decoded["issues"], "Issues should never be null")
+
+	hr.Content = &resource.Page{TargetURL: finalURL, PageType: resource.PageType{ContType: "text/html; charset=utf-8", MedType: "text/html"}}
+	suite.Equal("text/html", hr.Export().MediaType)
+
+	encoded, _ = json.Marshal(LinkExport{})
+	suite.Contains(string(encoded), `"version":1`, "The version should always be written")
+}
+
+func (suite *LinkSuite) TestCleanupWithoutAttachments() {
+	hr := &TraversedLink{OrigURLText: "https://www.netspective.com/", OrigLink: &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test03"}}
+	suite.Nil(hr.Cleanup(), "Cleanup should be a no-op without attachments")
+	suite.False(hr.IsAttachment(), "A link without content is not an attachment")
+}
+
+func (suite *LinkSuite) TestIsAttachment() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/paper.pdf" {
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4\n%%EOF\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	factory := NewFactory()
+	_, link, err := factory.TraverseLink(context.Background(), server.URL+"/paper.pdf")
+	suite.Nil(err, "No error expected")
+	suite.False(link.(*TraversedLink).WasDownloaded(), "Nothing is downloaded without an attachments creator")
+	suite.True(link.(*TraversedLink).IsAttachment(), "A PDF should be an attachment even if it wasn't downloaded")
+
+	_, link, err = factory.TraverseLink(context.Background(), server.URL+"/page")
+	suite.Nil(err, "No error expected")
+	suite.False(link.(*TraversedLink).IsAttachment(), "An HTML page is not an attachment")
+
+	suite.False((&TraversedLink{Content: &resource.Page{}}).IsAttachment(), "Content without a type is not an attachment")
+}
+
+func (suite *LinkSuite) TestCleanupDeletesAttachments() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4\n%%EOF\n"))
+	}))
+	defer server.Close()
+
+	_, link, err := suite.factory.TraverseLink(context.Background(), server.URL+"/paper.pdf")
+	suite.Nil(err, "No error expected")
+	hr := link.(*TraversedLink)
+	fa, ok := hr.Content.Attachment().(*resource.FileAttachment)
+	suite.Require().True(ok, "Attachment should be a FileAttachment type")
+	_, err = os.Stat(fa.DestPath)
+	suite.Nil(err, "File %s should exist", fa.DestPath)
+
+	reader, err := hr.OpenAttachment()
+	suite.Require().Nil(err, "The downloaded attachment should open")
+	body, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	suite.Equal("%PDF-1.4\n%%EOF\n", string(body), "The attachment should hold the downloaded bytes")
+
+	suite.Nil(hr.Cleanup(), "Cleanup should delete the attachment")
+	_, err = os.Stat(fa.DestPath)
+	suite.True(os.IsNotExist(err), "File %s should not exist", fa.DestPath)
+	suite.NotNil(hr.Cleanup(), "Deleting an already deleted attachment should be reported")
+
+	_, err = hr.OpenAttachment()
+	suite.True(xerrors.Is(err, os.ErrNotExist), "Opening a deleted attachment should report the missing file")
+	_, err = new(TraversedLink).OpenAttachment()
+	suite.True(xerrors.Is(err, ErrNoAttachment), "Opening without an attachment should fail clearly")
+}
+
+func (suite *LinkSuite) TestUnwrapURLs() {
+	inner, ok := suite.factory.unwrapURL("https://www.google.com/url?sa=t&q=https%3A%2F%2Fwww.netspective.com%2F%3Futm_source%3Dgoogle&usg=abc")
+	suite.True(ok, "Google's redirector should be unwrapped")
+	suite.Equal("https://www.netspective.com/?utm_source=google", inner)
+
+	inner, ok = suite.factory.unwrapURL("https://12ft.io/proxy?q=https%3A%2F%2Fwww.google.co.uk%2Furl%3Furl%3Dhttps%3A%2F%2Fwww.netspective.com%2F")
+	suite.True(ok, "Nested wrappers should be unwrapped")
+	suite.Equal("https://www.netspective.com/", inner)
+
+	_, ok = suite.factory.unwrapURL("https://www.google.com/url?q=javascript:alert(1)")
+	suite.False(ok, "Only http(s) URLs should be unwrapped")
+	_, ok = suite.factory.unwrapURL("https://www.google.com/search?q=https://www.netspective.com/")
+	suite.False(ok, "Search URLs aren't wrappers")
+
+	normalized, _, _, cleanedParams, err := suite.factory.Preview(context.Background(), "https://www.google.com/url?q=https%3A%2F%2Fwww.netspective.com%2F%3Futm_source%3Dgoogle")
+	suite.Nil(err, "No error expected")
+	suite.Equal("https://www.netspective.com/", normalized.String(), "The inner URL should be cleaned")
+	suite.Len(cleanedParams, 1)
+}
+
+func (suite *LinkSuite) TestFactoryConfigJSON() {
+	f := NewFactory()
+	f.IgnoreURLsRegExprs = append(f.IgnoreURLsRegExprs, regexp.MustCompile(`^https://example\.com/private/`))
+	f.RemoveParamValuesRegEx = []*regexp.Regexp{regexp.MustCompile(`^session-`)}
+	f.CleanFragmentParams = true
+	f.WarnTLSExpiryWithin = 72 * time.Hour
+
+	encoded, err := json.Marshal(f)
+	suite.Nil(err, "No error expected")
+	suite.Contains(string(encoded), `"^https://example\\.com/private/"`, "Regexes should be stored as patterns")
+
+	restored := NewFactory()
+	suite.Nil(json.Unmarshal(encoded, restored), "No error expected")
+	suite.Equal(f.Config(), restored.Config(), "The configuration should round-trip")
+	suite.True(restored.IgnoreURLsRegExprs[2].MatchString("https://example.com/private/page"))
+
+	partial := NewFactory()
+	suite.Nil(json.Unmarshal([]byte(`{"cleanFragmentParams": true}`), partial))
+	suite.True(partial.CleanFragmentParams)
+	suite.Equal(regexPatterns(DefaultTrackingParamRegexes()), regexPatterns(partial.RemoveParamsFromURLsRegEx), "Missing fields keep their values")
+
+	err = json.Unmarshal([]byte(`{"ignoreURLsRegExprs": ["("]}`), partial)
+	suite.NotNil(err, "Invalid patterns should be reported")
+	suite.Contains(err.Error(), "ignoreURLsRegExprs")
+	suite.True(partial.CleanFragmentParams, "A failed unmarshal should leave the factory unchanged")
+	suite.Len(partial.IgnoreURLsRegExprs, 2)
+}
+
+func (suite *LinkSuite) TestString() {
+	finalURL, _ := url.Parse("https://www.netspective.com/")
+	hr := &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test01", FinalizedURL: finalURL, IsURLValid: true, HTTPStatusCode: 200}
+	suite.Equal("http://bit.ly/lectio_harvester_resource_test01 -> https://www.netspective.com/ (valid: true, ignored: false, status: 200)", hr.String())
+	suite.Equal(hr.String(), fmt.Sprintf("%v", hr), "%v should use String()")
+
+	hr = &TraversedLink{OrigURLText: "https://t"}
+	suite.Equal("https://t -> <nil> (valid: false, ignored: false, status: 0)", hr.String())
+}
+
 func (suite *LinkSuite) TestOpenGraphMetaTags() {
 	hr := suite.traverseSingleURLFromMockTweet("Test a good URL %s which will redirect to a URL we want to ignore, with utm_* params", "http://bit.ly/lectio_harvester_resource_test01")
 	suite.True(hr.IsURLValid, "URL should be formatted validly")
@@ -95,9 +1297,11 @@ func (suite *LinkSuite) TestOpenGraphMetaTags() {
 
 	value, _, _ = hr.Content.MetaTag("og:title")
 	suite.Equal(value, "Safety, privacy, and security focused technology consulting")
+	suite.Equal(value, hr.DisplayTitle(), "DisplayTitle should prefer og:title")
 
 	value, _, _ = hr.Content.MetaTag("og:description")
 	suite.Equal(value, "Software, technology, and management consulting focused on firms im pacted by FDA, ONC, NIST or other safety, privacy, and security regulations")
+	suite.Equal(value, hr.Summary(), "Summary should prefer og:description")
 }
 
 func (suite *LinkSuite) TestIgnoreRules() {
@@ -105,10 +1309,15 @@ func (suite *LinkSuite) TestIgnoreRules() {
 	suite.True(hr.IsURLValid, "URL should be formatted validly")
 	suite.True(hr.IsURLIgnored, "URL should be ignored (skipped)")
 	suite.Equal(hr.IgnoreReason, "Matched Ignore Rule `^https://twitter.com/(.*?)/status/(.*)$`")
+	suite.Equal(hr.IgnoreRule.String(), "^https://twitter.com/(.*?)/status/(.*)$", "The matched rule should be recorded")
 
 	finalURL, issue := hr.FinalURL()
 	suite.Nil(issue, "Ensure there is no issue")
 	suite.Equal("https://twitter.com/Live5News/status/993220120402161664/photo/1", finalURL.String())
+
+	_, _, err := suite.factory.TraverseLinkStrict(context.Background(), "https://t.co/xNzrxkHE1u")
+	suite.True(xerrors.Is(err, ErrLinkIgnored), "Strict traversal should report ignored links as errors")
+	suite.Contains(err.Error(), hr.IgnoreReason)
 }
 
 func (suite *LinkSuite) TestResolvedURLRedirectedThroughHTMLProperly() {
@@ -118,6 +1327,9 @@ func (suite *LinkSuite) TestResolvedURLRedirectedThroughHTMLProperly() {
 	isHTMLRedirect, htmlRedirectURLText := hr.Content.Redirect()
 	suite.True(isHTMLRedirect, "There should have been an HTML redirect requested through <meta http-equiv='refresh' content='delay;url='>")
 	suite.Equal(htmlRedirectURLText, "https://www.netspective.com/?utm_source=lectio_harvester_resource_test.go&utm_medium=go.TestSuite&utm_campaign=harvester.ResourceSuite")
+	isHTMLRedirect, htmlRedirectURL := hr.Redirect()
+	suite.True(isHTMLRedirect, "Link should report the HTML redirect")
+	suite.Equal(htmlRedirectURLText, htmlRedirectURL.String(), "Absolute redirect URL should be unchanged when resolved")
 	suite.NotNil(hr.Content, "Inspection results should be available")
 
 	// at this point we want to get the "new" (redirected) and test it
@@ -137,6 +1349,43 @@ func (suite *LinkSuite) TestResolvedURLRedirectedThroughHTMLProperly() {
 	suite.NotNil(redirectedLink.Content, "Inspection results should be available")
 }
 
+func (suite *LinkSuite) TestPerCallPolicyOverrides() {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/refresh" {
+			fmt.Fprintf(w, `<html><head><meta http-equiv="refresh" content="0;url=%s/dest"></head></html>`, server.URL)
+			return
+		}
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	suite.followHTMLRedirects = true
+	defer func() { suite.followHTMLRedirects = false }()
+
+	_, link, err := suite.factory.TraverseLink(ctx, server.URL+"/refresh")
+	suite.Nil(err, "No error expected")
+	suite.NotNil(link.(*TraversedLink).OrigLink, "The factory's redirect policy should follow the HTML redirect")
+
+	_, link, err = suite.factory.TraverseLink(ctx, server.URL+"/refresh", FollowHTMLRedirects(false))
+	suite.Nil(err, "No error expected")
+	hr := link.(*TraversedLink)
+	suite.Nil(hr.OrigLink, "The per-call option should take precedence over the factory's redirect policy")
+	isHTMLRedirect, redirectURL := hr.Redirect()
+	suite.True(isHTMLRedirect, "The HTML redirect should be reported but not followed")
+	suite.Equal(server.URL+"/dest", redirectURL.String())
+
+	keepAll := NewFactory()
+	keepAll.RemoveParamsFromURLsRegEx = nil
+	url, _ := url.Parse("https://www.netspective.com/?utm_source=test&id=1")
+	cleaned, _, _ := suite.factory.cleanLink(withPolicyOverrides(ctx, []interface{}{keepAll}), url)
+	suite.False(cleaned, "The overriding cleaning policy should keep every param")
+	cleaned, _, _ = suite.factory.cleanLink(ctx, url)
+	suite.True(cleaned, "Without an override the factory's cleaning policy applies")
+}
+
 func (suite *LinkSuite) TestResolvedURLCleaned() {
 	hr := suite.traverseSingleURLFromMockTweet("Test a good URL %s which will redirect to a URL we want to ignore, with utm_* params", "http://bit.ly/lectio_harvester_resource_test01")
 	suite.True(hr.IsURLValid, "URL should be formatted validly")
@@ -159,6 +1408,127 @@ func (suite *LinkSuite) TestResolvedURLCleanedKeys() {
 	suite.NotNil(hr.Content, "Inspection results should be available")
 }
 
+func (suite *LinkSuite) TestSemicolonSeparatedParamsCleaned() {
+	ctx := context.Background()
+	suite.factory.SemicolonSeparatesQueryParams = true
+	defer func() { suite.factory.SemicolonSeparatesQueryParams = false }()
+
+	url, _ := url.Parse("https://www.netspective.com/page?id=1;utm_source=test;page=2")
+	cleaned, cleanedURL, _ := suite.factory.cleanLink(ctx, url)
+	suite.True(cleaned, "URL should be 'cleaned'")
+	suite.Equal("https://www.netspective.com/page?id=1&page=2", cleanedURL.String())
+}
+
+func (suite *LinkSuite) TestParamValuesCleaned() {
+	ctx := context.Background()
+	suite.factory.RemoveParamValuesRegEx = []*regexp.Regexp{regexp.MustCompile(`^sess-[0-9a-f]+$`)}
+	defer func() { suite.factory.RemoveParamValuesRegEx = nil }()
+
+	url, _ := url.Parse("https://www.netspective.com/page?id=1&token=sess-4f2a9c&utm_source=test")
+	cleaned, cleanedURL, _ := suite.factory.cleanLink(ctx, url)
+	suite.True(cleaned, "URL should be 'cleaned'")
+	suite.Equal("https://www.netspective.com/page?id=1", cleanedURL.String(), "Params should be removed by name or by value")
+}
+
+func (suite *LinkSuite) TestFragmentParamsCleaned() {
+	ctx := context.Background()
+	suite.factory.CleanFragmentParams = true
+	defer func() { suite.factory.CleanFragmentParams = false }()
+
+	url, _ := url.Parse("https://www.netspective.com/app#/solutions?id=1&utm_source=test")
+	cleaned, cleanedURL, _ := suite.factory.cleanLink(ctx, url)
+	suite.True(cleaned, "URL should be 'cleaned'")
+	suite.Equal("https://www.netspective.com/app#/solutions?id=1", cleanedURL.String())
+
+	url, _ = url.Parse("https://www.netspective.com/page?id=1#utm_source=test&utm_medium=go")
+	cleaned, cleanedURL, _ = suite.factory.cleanLink(ctx, url)
+	suite.True(cleaned, "URL should be 'cleaned'")
+	suite.Equal("https://www.netspective.com/page?id=1", cleanedURL.String())
+
+	url, _ = url.Parse("https://www.netspective.com/page#section-2")
+	cleaned, _, _ = suite.factory.cleanLink(ctx, url)
+	suite.False(cleaned, "Plain anchors should not be touched")
+}
+
+func (suite *LinkSuite) TestDefaultTrackingParamsCleaned() {
+	ctx := context.Background()
+	for _, param := range []string{"utm_source", "utm_campaign", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "igshid", "_ga", "_gl"} {
+		url, _ := url.Parse("https://www.netspective.com/news?id=123&page=2&" + param + "=tracking")
+		cleaned, cleanedURL, _ := suite.factory.cleanLink(ctx, url)
+		suite.True(cleaned, "URL with %s should be 'cleaned'", param)
+		suite.Equal("https://www.netspective.com/news?id=123&page=2", cleanedURL.String(), "%s should be removed while id and page survive", param)
+	}
+}
+
+func (suite *LinkSuite) TestHostChangedWarning() {
+	finalURL, _ := url.Parse("https://www.netspective.com/")
+	hr := &TraversedLink{OrigURLText: "http://bit.ly/lectio_harvester_resource_test03", IsURLValid: true, FinalizedURL: finalURL}
+	suite.factory.checkHostChanged(hr)
+	suite.Len(hr.Issues, 0, "Host changes are only reported when requested")
+
+	suite.factory.WarnOnHostChange = true
+	defer func() { suite.factory.WarnOnHostChange = false }()
+	suite.factory.checkHostChanged(hr)
+	suite.Len(hr.Issues, 1)
+	suite.Equal("LECTIOLINK-012-HOSTCHANGED", hr.Issues[0].Code)
+	suite.Contains(hr.Issues[0].Message, `"bit.ly" to "www.netspective.com"`)
+
+	hr = &TraversedLink{OrigURLText: "https://WWW.Netspective.com/solutions", IsURLValid: true, FinalizedURL: finalURL}
+	suite.factory.checkHostChanged(hr)
+	suite.Len(hr.Issues, 0, "Host case differences are not a change")
+
+	hr = &TraversedLink{OrigURLText: "https://www.netspective.com/", IsURLValid: true, FinalizedURL: finalURL,
+		OrigLink: &TraversedLink{OrigURLText: "https://netspective.com/"}}
+	suite.factory.checkHostChanged(hr)
+	suite.Len(hr.Issues, 1, "The host of the first link in the chain should be compared")
+
+	hr = &TraversedLink{OrigURLText: "https://www.google.com/url?q=https://www.netspective.com/", UnwrappedURLText: "https://www.netspective.com/",
+		IsURLValid: true, FinalizedURL: finalURL}
+	suite.factory.checkHostChanged(hr)
+	suite.Len(hr.Issues, 0, "The unwrapped URL's host should be compared")
+}
+
+func (suite *LinkSuite) TestCleaningDisabled() {
+	ctx := context.Background()
+	suite.factory.EnableCleaning = false
+	defer func() { suite.factory.EnableCleaning = true }()
+
+	resolvedURL, _ := url.Parse("https://www.netspective.com/view?id=123&utm_source=test&fbclid=abc")
+	hr := &TraversedLink{IsURLValid: true, ResolvedURL: resolvedURL, FinalizedURL: resolvedURL}
+	suite.factory.cleanTraversedLink(ctx, hr)
+	suite.False(hr.AreURLParamsCleaned, "URL should not be 'cleaned'")
+	suite.Nil(hr.CleanedURL, "cleanedURL should be empty")
+	suite.Equal(hr.ResolvedURL.String(), hr.FinalizedURL.String(), "finalURL should be same as resolvedURL")
+	suite.Equal("https://www.netspective.com/view?id=123&utm_source=test&fbclid=abc", hr.FinalizedURL.String(), "Params should survive untouched")
+}
+
+func (suite *LinkSuite) TestAllParamsCleanedWarning() {
+	ctx := context.Background()
+	resolvedURL, _ := url.Parse("https://www.netspective.com/view?utm_source=test&utm_medium=go")
+	hr := &TraversedLink{IsURLValid: true, ResolvedURL: resolvedURL, FinalizedURL: resolvedURL}
+	suite.factory.cleanTraversedLink(ctx, hr)
+	suite.True(hr.AreURLParamsCleaned, "URL should be 'cleaned'")
+	suite.Equal("https://www.netspective.com/view", hr.FinalizedURL.String())
+	suite.Len(hr.Issues, 1, "Removing every param should produce a warning")
+	suite.Equal("LECTIOLINK-006-ALLPARAMSCLEANED", hr.Issues[0].Code)
+	suite.Contains(hr.Issues[0].Message, "utm_medium, utm_source")
+	suite.Len(hr.CleanedParams, 2, "Both removed params should be recorded")
+	for _, param := range hr.CleanedParams {
+		suite.Contains([]string{"utm_source", "utm_medium"}, param.ParamName)
+		suite.Contains(param.Reason, "Matched cleaner rule")
+	}
+
+	var warnings []string
+	suite.True(hr.Traversable(func(code, message string) { warnings = append(warnings, code) }), "Issues should not affect traversability")
+	suite.Equal([]string{"LECTIOLINK-006-ALLPARAMSCLEANED"}, warnings)
+
+	resolvedURL, _ = url.Parse("https://www.netspective.com/view?id=123&utm_source=test")
+	hr = &TraversedLink{IsURLValid: true, ResolvedURL: resolvedURL, FinalizedURL: resolvedURL}
+	suite.factory.cleanTraversedLink(ctx, hr)
+	suite.True(hr.AreURLParamsCleaned, "URL should be 'cleaned'")
+	suite.Len(hr.Issues, 0, "Functional params survived so there should be no warning")
+}
+
 func (suite *LinkSuite) TestResolvedURLNotCleaned() {
 	hr := suite.traverseSingleURLFromMockTweet("Test a good URL %s which will redirect to a URL we want to ignore", "https://t.co/ELrZmo81wI")
 	suite.True(hr.IsURLValid, "URL should be formatted validly")
@@ -172,6 +1542,9 @@ func (suite *LinkSuite) TestResolvedURLNotCleaned() {
 	suite.NotNil(content, "The destination content should be available")
 	suite.True(content.IsValid(), "The destination content should be valid")
 	suite.True(content.IsHTML(), "The destination content should be HTML")
+	suite.True(hr.IsHTML(), "The link should report HTML content")
+	suite.False(hr.IsAttachment(), "HTML content is not an attachment")
+	suite.False(hr.WasDownloaded(), "HTML content should not be downloaded")
 }
 
 func (suite *LinkSuite) TestResolvedDocumentURLNotCleaned() {
@@ -183,6 +1556,10 @@ func (suite *LinkSuite) TestResolvedDocumentURLNotCleaned() {
 	suite.Equal(hr.FinalizedURL.String(), hr.ResolvedURL.String(), "finalURL should be same as resolvedURL")
 	suite.Nil(hr.CleanedURL, "cleanedURL should be empty")
 
+	suite.True(hr.WasDownloaded(), "The link should report it was downloaded")
+	suite.False(hr.IsHTML(), "A PDF is not HTML")
+	suite.True(hr.IsAttachment(), "A PDF should be treated as an attachment")
+
 	attachment := hr.Content.Attachment()
 	suite.NotNil(attachment, "Should have an attachment")
 	suite.True(attachment.IsValid(), "First attachment should be valid")
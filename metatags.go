@@ -0,0 +1,137 @@
+package link
+
+import (
+	"github.com/lectio/resource"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TwitterCard is the structured form of a page's twitter:* meta tags
+type TwitterCard struct {
+	Card        string   `json:"card,omitempty"`
+	Site        string   `json:"site,omitempty"`
+	Creator     string   `json:"creator,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	Images      []string `json:"images,omitempty"`
+}
+
+// metaTagTexts returns the trimmed, non-empty values of the given meta tags in key order. resource keeps a single
+// string per tag name (the last one in the page), so a repeated tag such as article:tag only contributes its last value.
+func metaTagTexts(content resource.Content, keys ...string) []string {
+	if content == nil || content.Type() == nil {
+		return nil // resource.Page can't look up meta tags without a Content-Type
+	}
+
+	var texts []string
+	for _, key := range keys {
+		value, _, _ := content.MetaTag(key)
+		if s, ok := value.(string); ok {
+			if text := strings.TrimSpace(s); len(text) > 0 {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return texts
+}
+
+// metaTagText returns the first non-empty value among the given meta tags
+func metaTagText(content resource.Content, keys ...string) string {
+	if texts := metaTagTexts(content, keys...); len(texts) > 0 {
+		return texts[0]
+	}
+	return ""
+}
+
+// Summary returns the best available description of the link's content, preferring og:description,
+// then twitter:description, then the plain <meta name="description">. It returns an empty string
+// when the content has none of them.
+func (l *TraversedLink) Summary() string {
+	return metaTagText(l.Content, "og:description", "twitter:description", "description")
+}
+
+// Tags returns the content's topic tags: the article:tag value (only the last one when it's repeated, see
+// metaTagTexts) followed by the comma-separated <meta name="keywords">, trimmed and de-duplicated (ignoring case).
+// It returns an empty slice when there are none.
+func (l *TraversedLink) Tags() []string {
+	return mergeTags(metaTagTexts(l.Content, "article:tag"), metaTagTexts(l.Content, "keywords"))
+}
+
+// mergeTags splits the keywords lists on commas and merges them after tags, keeping the first spelling of each tag
+func mergeTags(tags []string, keywordLists []string) []string {
+	for _, keywords := range keywordLists {
+		tags = append(tags, strings.Split(keywords, ",")...)
+	}
+
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if len(tag) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// DisplayTitle returns the best human-readable title for the link: og:title, then twitter:title, then a title
+// derived from the URL's last path segment (or hostname). The HTML <title> isn't used because resource only parses
+// meta tags. Ignored links and links without content always use the URL-derived title.
+func (l *TraversedLink) DisplayTitle() string {
+	if l.Content != nil && !l.IsURLIgnored {
+		if title := metaTagText(l.Content, "og:title", "twitter:title"); len(title) > 0 {
+			return title
+		}
+	}
+
+	titleURL := l.FinalizedURL
+	if titleURL == nil {
+		titleURL, _ = url.Parse(l.OrigURLText)
+	}
+	return urlDisplayTitle(titleURL)
+}
+
+// urlDisplayTitle prettifies the URL's last path segment (e.g. "/blog/opsfolio-launch.html" becomes
+// "Opsfolio Launch"), falling back to the simplified hostname
+func urlDisplayTitle(titleURL *url.URL) string {
+	if titleURL == nil {
+		return ""
+	}
+
+	segment := path.Base(strings.TrimSuffix(titleURL.Path, "/"))
+	segment = strings.TrimSuffix(segment, path.Ext(segment))
+	words := strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '+' || r == ' ' || r == '/' || r == '.' })
+	if len(words) == 0 {
+		return GetSimplifiedHostname(titleURL)
+	}
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// TwitterCard returns the link content's Twitter Card, or nil when the content has no twitter:* meta tags.
+// Image is the first image; Images has the twitter:image and legacy twitter:image:src values (the last of each).
+func (l *TraversedLink) TwitterCard() *TwitterCard {
+	card := &TwitterCard{
+		Card:        metaTagText(l.Content, "twitter:card"),
+		Site:        metaTagText(l.Content, "twitter:site"),
+		Creator:     metaTagText(l.Content, "twitter:creator"),
+		Title:       metaTagText(l.Content, "twitter:title"),
+		Description: metaTagText(l.Content, "twitter:description"),
+		Images:      metaTagTexts(l.Content, "twitter:image", "twitter:image:src"),
+	}
+	if len(card.Images) > 0 {
+		card.Image = card.Images[0]
+	}
+
+	if len(card.Card) == 0 && len(card.Site) == 0 && len(card.Creator) == 0 && len(card.Title) == 0 && len(card.Description) == 0 && len(card.Image) == 0 {
+		return nil
+	}
+	return card
+}
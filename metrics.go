@@ -0,0 +1,106 @@
+package link
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The outcomes passed to MetricsCollector.IncTraversed
+const (
+	TraversalOutcomeTraversed = "traversed"
+	TraversalOutcomeIgnored   = "ignored"
+	TraversalOutcomeInvalid   = "invalid"
+)
+
+// MetricsCollector receives counters and timings from the factory, e.g. to export them as Prometheus metrics.
+// It's optional: a factory without a collector doesn't gather any metrics. Implementations must be thread-safe.
+// IncBytesDownloaded counts response body bytes as they're read, so content which is neither inspected as HTML nor
+// downloaded as an attachment adds nothing.
+type MetricsCollector interface {
+	IncTraversed(outcome string)
+	ObserveDuration(d time.Duration)
+	IncBytesDownloaded(n int64)
+}
+
+// InMemoryMetrics is a MetricsCollector which keeps running totals in memory
+type InMemoryMetrics struct {
+	mutex         sync.Mutex
+	outcomes      map[string]int64
+	observations  int64
+	totalDuration time.Duration
+	bytes         int64
+}
+
+// NewInMemoryMetrics creates an empty in-memory metrics collector
+func NewInMemoryMetrics() *InMemoryMetrics {
+	return &InMemoryMetrics{outcomes: make(map[string]int64)}
+}
+
+// IncTraversed satisfies MetricsCollector
+func (m *InMemoryMetrics) IncTraversed(outcome string) {
+	m.mutex.Lock()
+	m.outcomes[outcome]++
+	m.mutex.Unlock()
+}
+
+// ObserveDuration satisfies MetricsCollector
+func (m *InMemoryMetrics) ObserveDuration(d time.Duration) {
+	m.mutex.Lock()
+	m.observations++
+	m.totalDuration += d
+	m.mutex.Unlock()
+}
+
+// IncBytesDownloaded satisfies MetricsCollector
+func (m *InMemoryMetrics) IncBytesDownloaded(n int64) {
+	atomic.AddInt64(&m.bytes, n)
+}
+
+// Traversed returns the number of traversals with the given outcome (e.g. TraversalOutcomeIgnored)
+func (m *InMemoryMetrics) Traversed(outcome string) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.outcomes[outcome]
+}
+
+// Durations returns the number of timed traversals and their total duration
+func (m *InMemoryMetrics) Durations() (int64, time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.observations, m.totalDuration
+}
+
+// BytesDownloaded returns the number of response body bytes read so far
+func (m *InMemoryMetrics) BytesDownloaded() int64 {
+	return atomic.LoadInt64(&m.bytes)
+}
+
+// traversalOutcome classifies a traversed link for IncTraversed
+func traversalOutcome(link Link) string {
+	traversed, ok := link.(*TraversedLink)
+	switch {
+	case !ok || !traversed.IsURLValid:
+		return TraversalOutcomeInvalid
+	case traversed.IsURLIgnored:
+		return TraversalOutcomeIgnored
+	default:
+		return TraversalOutcomeTraversed
+	}
+}
+
+// meteredBody reports the bytes read from a response body to a MetricsCollector
+type meteredBody struct {
+	io.ReadCloser
+	metrics MetricsCollector
+}
+
+// Read satisfies io.Reader
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.metrics.IncBytesDownloaded(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,104 @@
+package link
+
+import (
+	"context"
+	"sync"
+)
+
+// TraversalResult bundles the outcome of traversing a single URL received by TraverseLinkStream
+type TraversalResult struct {
+	OrigURLText string
+	Traversable bool
+	Link        Link
+	Error       error
+}
+
+// TraverseLinkStream traverses each URL received from in using up to concurrency workers and emits results as
+// they complete, so they are not necessarily in input order. The returned channel is closed once in is closed
+// and drained (or ctx is done) and all workers have finished.
+func (f *DefaultFactory) TraverseLinkStream(ctx context.Context, in <-chan string, concurrency int, options ...interface{}) <-chan TraversalResult {
+	return f.traverseLinkStream(ctx, in, concurrency, nil, options...)
+}
+
+// TraverseUniqueLinkStream is like TraverseLinkStream but deduplicates during the batch: each distinct URL text is
+// traversed only once, and inputs which finalize to a URL that was already traversed share that link. There is still
+// one result per input; a duplicate's result is emitted after the traversal it shares has completed.
+func (f *DefaultFactory) TraverseUniqueLinkStream(ctx context.Context, in <-chan string, concurrency int, options ...interface{}) <-chan TraversalResult {
+	return f.traverseLinkStream(ctx, in, concurrency, new(traversalDeduplicator), options...)
+}
+
+func (f *DefaultFactory) traverseLinkStream(ctx context.Context, in <-chan string, concurrency int, dedup *traversalDeduplicator, options ...interface{}) <-chan TraversalResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	out := make(chan TraversalResult)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case origURLtext, ok := <-in:
+					if !ok {
+						return
+					}
+					var result TraversalResult
+					if dedup != nil {
+						result = dedup.traverse(ctx, f, origURLtext, options...)
+					} else {
+						traversable, link, err := f.TraverseLink(ctx, origURLtext, options...)
+						result = TraversalResult{origURLtext, traversable, link, err}
+					}
+					select {
+					case out <- result:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// traversalDeduplicator tracks the traversals of a single TraverseUniqueLinkStream batch
+type traversalDeduplicator struct {
+	byURLText  sync.Map // URL text -> *dedupedTraversal
+	byFinalURL sync.Map // finalized URL text -> Link
+}
+
+// dedupedTraversal is the shared outcome of traversing one URL text; done is closed once result is available
+type dedupedTraversal struct {
+	done   chan struct{}
+	result TraversalResult
+}
+
+func (d *traversalDeduplicator) traverse(ctx context.Context, f *DefaultFactory, origURLtext string, options ...interface{}) TraversalResult {
+	entry, loaded := d.byURLText.LoadOrStore(origURLtext, &dedupedTraversal{done: make(chan struct{})})
+	traversal := entry.(*dedupedTraversal)
+	if loaded {
+		<-traversal.done
+		return traversal.result
+	}
+
+	traversable, link, err := f.TraverseLink(ctx, origURLtext, options...)
+	if err == nil && link != nil {
+		if finalURL, finalURLErr := link.FinalURL(); finalURLErr == nil && finalURL != nil {
+			shared, _ := d.byFinalURL.LoadOrStore(finalURL.String(), link)
+			link = shared.(Link)
+		}
+	}
+	traversal.result = TraversalResult{origURLtext, traversable, link, err}
+	close(traversal.done)
+	return traversal.result
+}
@@ -0,0 +1,29 @@
+package link
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// TLSInfo describes the server certificate presented when an HTTPS link was fetched
+type TLSInfo struct {
+	Issuer    string    `json:"issuer"`
+	SubjectCN string    `json:"subjectCN"`
+	NotAfter  time.Time `json:"notAfter"`
+	Verified  bool      `json:"verified"`
+}
+
+// newTLSInfo summarizes the leaf certificate of the given connection, or returns nil for plain HTTP
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := state.PeerCertificates[0]
+	return &TLSInfo{
+		Issuer:    cert.Issuer.String(),
+		SubjectCN: cert.Subject.CommonName,
+		NotAfter:  cert.NotAfter,
+		Verified:  len(state.VerifiedChains) > 0,
+	}
+}
@@ -0,0 +1,308 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"golang.org/x/xerrors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// linkTransport is the http.RoundTripper used by the factory's client; it answers data: URLs (and file: URLs,
+// when allowed) locally and passes all other requests to the underlying transport
+type linkTransport struct {
+	factory *DefaultFactory
+	next    http.RoundTripper
+	files   http.RoundTripper
+}
+
+// httpTrace records what happened at the HTTP level while a single link was fetched; it travels in the
+// request's context so the shared client can attribute redirects and responses to the right traversal
+type httpTrace struct {
+	mutex         sync.Mutex
+	redirectCount int
+	redirectURLs  []*url.URL
+	redirectHops  []HTTPRedirectHop // only recorded when DefaultFactory.RecordRedirectHops is set
+	statusCode    int
+	tls           *tls.ConnectionState
+	location      string   // the Location header of the last response
+	contentTypes  []string // all Content-Type headers of the last response, when it had more than one
+	contentType   string   // the Content-Type chosen from contentTypes
+
+	contentTypeSniffed bool   // true if contentType was detected from the body because the server didn't send one
+	declaredLength     int64  // the Content-Length of the last response if it was a 200, -1 when unknown
+	dispositionType    string // the type of the last response's Content-Disposition header (e.g. "inline" or "attachment")
+
+	downloadErr     error // the error of the attachment download, if it failed
+	downloadStopped bool  // true if the caller's ContentDownloaderErrorPolicy stopped on downloadErr
+}
+
+type httpTraceContextKey struct{}
+
+// withHTTPTrace returns a context which records an httpTrace for requests made with it
+func withHTTPTrace(ctx context.Context) (context.Context, *httpTrace) {
+	trace := &httpTrace{declaredLength: -1}
+	return context.WithValue(ctx, httpTraceContextKey{}, trace), trace
+}
+
+// httpTraceFromContext returns the trace recorded for ctx, or nil if there isn't one
+func httpTraceFromContext(ctx context.Context) *httpTrace {
+	trace, _ := ctx.Value(httpTraceContextKey{}).(*httpTrace)
+	return trace
+}
+
+// newHTTPClient copies the provider's client, wrapping its transport with a linkTransport and its redirect
+// policy so that HTTP redirects are counted
+func (f *DefaultFactory) newHTTPClient() *http.Client {
+	client := *f.HTTPClientProvider.HTTPClient(context.Background())
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	next = f.limitConnsPerHost(next)
+	client.Transport = &linkTransport{factory: f, next: next, files: http.NewFileTransport(http.Dir("/"))}
+
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// every hop must pass the same scheme check as the link itself; a remote server may never redirect to the
+		// local disk, even when AllowFileScheme is set
+		if allowed, scheme := f.isSchemeAllowed(req.URL.String()); !allowed || scheme == "file" {
+			return xerrors.Errorf("redirect to %q: %w", req.URL.String(), ErrSchemeNotAllowed)
+		}
+
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return xerrors.New("stopped after 10 redirects")
+		}
+
+		if trace := httpTraceFromContext(req.Context()); trace != nil {
+			trace.mutex.Lock()
+			trace.redirectCount++
+			if len(trace.redirectURLs) == 0 {
+				trace.redirectURLs = append(trace.redirectURLs, via[0].URL)
+			}
+			trace.redirectURLs = append(trace.redirectURLs, req.URL)
+			if f.RecordRedirectHops && req.Response != nil {
+				trace.redirectHops = append(trace.redirectHops, HTTPRedirectHop{
+					URL:        via[len(via)-1].URL.String(),
+					StatusCode: req.Response.StatusCode,
+					Location:   req.Response.Header.Get("Location"),
+					SetCookies: req.Response.Header["Set-Cookie"],
+				})
+			}
+			trace.mutex.Unlock()
+		}
+		return nil
+	}
+	return &client
+}
+
+// limitConnsPerHost applies MaxConnsPerHost and MaxIdleConnsPerHost to a clone of an *http.Transport so that the
+// caller's transport (or http.DefaultTransport, which is shared by the whole process) isn't modified; RoundTrippers
+// which aren't an *http.Transport are returned unchanged
+func (f *DefaultFactory) limitConnsPerHost(next http.RoundTripper) http.RoundTripper {
+	if f.MaxConnsPerHost <= 0 && f.MaxIdleConnsPerHost <= 0 {
+		return next
+	}
+
+	transport, ok := next.(*http.Transport)
+	if !ok {
+		return next
+	}
+	transport = transport.Clone()
+	if f.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = f.MaxConnsPerHost
+	}
+	if f.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = f.MaxIdleConnsPerHost
+	}
+	return transport
+}
+
+// RoundTrip satisfies http.RoundTripper
+func (t *linkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTrip(req)
+	if err == nil {
+		sniffed := false
+		if req.Method == http.MethodGet && resp.StatusCode == http.StatusOK && len(resp.Header["Content-Type"]) == 0 && resp.Body != nil {
+			sniffed = sniffContentType(resp)
+		}
+		if metrics := t.factory.Metrics; metrics != nil && resp.Body != nil {
+			resp.Body = &meteredBody{resp.Body, metrics}
+		}
+
+		var contentTypes []string
+		if values := resp.Header["Content-Type"]; len(values) > 1 {
+			contentTypes = values
+			resp.Header.Set("Content-Type", chooseContentType(values))
+		}
+
+		if trace := httpTraceFromContext(req.Context()); trace != nil {
+			trace.mutex.Lock()
+			trace.statusCode = resp.StatusCode
+			trace.location = resp.Header.Get("Location")
+			if !t.factory.SkipTLSInfo {
+				trace.tls = resp.TLS
+			}
+			trace.contentTypes = contentTypes
+			trace.contentType = resp.Header.Get("Content-Type")
+			trace.contentTypeSniffed = sniffed
+			trace.declaredLength = -1
+			if resp.StatusCode == http.StatusOK {
+				trace.declaredLength = resp.ContentLength
+			}
+			trace.dispositionType = dispositionType(resp.Header.Get("Content-Disposition"))
+			trace.mutex.Unlock()
+		}
+	}
+	return resp, err
+}
+
+// sniffContentType sets the Content-Type of a response which didn't declare one from its first 512 bytes, using
+// http.DetectContentType, so resource can tell HTML from attachments; the bytes are put back in front of the body.
+// It returns false if the body was empty.
+func sniffContentType(resp *http.Response) bool {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	head = head[:n]
+	resp.Body = &sniffedBody{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	if n == 0 && err != nil {
+		return false
+	}
+	resp.Header.Set("Content-Type", http.DetectContentType(head))
+	return true
+}
+
+// sniffedBody is a response body whose first bytes were already read by sniffContentType
+type sniffedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// dispositionType returns the lower-cased type of a Content-Disposition header, even when its params are malformed
+func dispositionType(header string) string {
+	if disposition, _, err := mime.ParseMediaType(header); err == nil {
+		return disposition
+	}
+	disposition := strings.SplitN(header, ";", 2)[0]
+	return strings.ToLower(strings.TrimSpace(disposition))
+}
+
+// chooseContentType picks the most useful of several Content-Type headers sent by a misbehaving server: the
+// first parseable media type other than application/octet-stream, then the first parseable one, then the first
+func chooseContentType(values []string) string {
+	parseable := ""
+	for _, value := range values {
+		mediaType, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			continue
+		}
+		if mediaType != "application/octet-stream" {
+			return value
+		}
+		if len(parseable) == 0 {
+			parseable = value
+		}
+	}
+	if len(parseable) > 0 {
+		return parseable
+	}
+	return values[0]
+}
+
+func (t *linkTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	origReq := req
+	switch req.URL.Scheme {
+	case "data":
+		return dataURLResponse(req)
+	case "file":
+		if !t.factory.AllowFileScheme {
+			return nil, xerrors.Errorf("file: URLs are not allowed unless DefaultFactory.AllowFileScheme is set: %q", req.URL.String())
+		}
+		return t.files.RoundTrip(req)
+	}
+
+	// rewrite every outgoing request (including redirect hops) after the link's URL was unwrapped but before it's
+	// fetched; the link still records the original URL text
+	if rewrite := t.factory.PreRequestURLRewriter; rewrite != nil {
+		if rewrittenURL := rewrite(req.Context(), req.URL); rewrittenURL != nil && rewrittenURL != req.URL {
+			req = req.WithContext(req.Context())
+			req.URL = rewrittenURL
+			req.Host = ""
+		}
+	}
+
+	if accept := t.factory.policies(req.Context()).AcceptHeader; len(accept) > 0 && len(req.Header.Get("Accept")) == 0 {
+		// RoundTrippers must not modify the caller's request so the headers are copied
+		req = req.WithContext(req.Context())
+		header := make(http.Header, len(req.Header)+1)
+		for key, values := range req.Header {
+			header[key] = values
+		}
+		header.Set("Accept", accept)
+		req.Header = header
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if resp != nil {
+		// the response must refer to the caller's request so the rewritten URL doesn't become the resolved URL
+		resp.Request = origReq
+	}
+	return resp, err
+}
+
+// dataURLResponse decodes a data: URL (`data:[<mediatype>][;base64],<data>`) into a synthetic 200 response
+func dataURLResponse(req *http.Request) (*http.Response, error) {
+	dataURLText := req.URL.Opaque
+	if len(dataURLText) == 0 {
+		dataURLText = strings.TrimPrefix(req.URL.String(), "data:")
+	}
+
+	comma := strings.Index(dataURLText, ",")
+	if comma < 0 {
+		return nil, xerrors.Errorf("Invalid data URL, missing ',' separator: %q", req.URL.String())
+	}
+	mediaType, payload := dataURLText[:comma], dataURLText[comma+1:]
+
+	isBase64 := strings.HasSuffix(mediaType, ";base64")
+	mediaType = strings.TrimSuffix(mediaType, ";base64")
+	if len(mediaType) == 0 || strings.HasPrefix(mediaType, ";") {
+		mediaType = "text/plain" + mediaType
+		if !strings.Contains(mediaType, "charset=") {
+			mediaType += ";charset=US-ASCII"
+		}
+	}
+
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, xerrors.Errorf("Invalid data URL encoding: %w", err)
+	}
+	body := []byte(data)
+	if isBase64 {
+		body, err = base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, xerrors.Errorf("Invalid data URL base64 payload: %w", err)
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        http.Header{"Content-Type": []string{mediaType}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
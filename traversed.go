@@ -1,26 +1,85 @@
 package link
 
 import (
+	"fmt"
 	"github.com/lectio/resource"
+	"golang.org/x/xerrors"
+	"io"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // TraversedLink tracks a single URL that was curated or discovered in Content.
 // Discovered URLs are validated, follow their redirects, and may have
-// query parameters "cleaned" (if instructed).
+// query parameters "cleaned" (if instructed). RedirectChain lists the URLs
+// visited through HTTP redirects (requested URL first), with hops that only
+// differ by case or a trailing slash collapsed; HTTPRedirectCount is the raw
+// number of HTTP redirects. UnwrappedURLText is the URL which was fetched
+// instead of OrigURLText when OrigURLText was a known wrapper (e.g. Google's
+// /url?q=). IgnoreRule is the rule which matched when the link
+// was ignored by a policy implementing IgnoreLinkRulePolicy. ContentTypeSniffed
+// is true when the server sent no Content-Type and the media type was
+// detected from the first 512 bytes of the body instead. DeclaredLength is
+// the Content-Length of the final response, -1 when it was absent or the
+// link wasn't fetched.
 type TraversedLink struct {
-	TraversedOn         time.Time        `json:"traversedOn,omitempty"`
-	OrigURLText         string           `json:"origURLtext"`
-	OrigLink            *TraversedLink   `json:"origLink,omitempty"`
-	IsURLValid          bool             `json:"isURLValid"`
-	IsURLIgnored        bool             `json:"isURLIgnored"`
-	IgnoreReason        string           `json:"ignoreReason"`
-	AreURLParamsCleaned bool             `json:"areURLParamsCleaned"`
-	ResolvedURL         *url.URL         `json:"resolvedURL"`
-	CleanedURL          *url.URL         `json:"cleanedURL"`
-	FinalizedURL        *url.URL         `json:"finalizedURL"`
-	Content             resource.Content `json:"content"`
+	TraversedOn         time.Time         `json:"traversedOn,omitempty"`
+	OrigURLText         string            `json:"origURLtext"`
+	OrigLink            *TraversedLink    `json:"origLink,omitempty"`
+	UnwrappedURLText    string            `json:"unwrappedURLText,omitempty"`
+	IsURLValid          bool              `json:"isURLValid"`
+	InvalidURLCode      string            `json:"invalidURLCode,omitempty"`
+	IsURLIgnored        bool              `json:"isURLIgnored"`
+	IgnoreReason        string            `json:"ignoreReason"`
+	IgnoreRule          *regexp.Regexp    `json:"-"`
+	AreURLParamsCleaned bool              `json:"areURLParamsCleaned"`
+	CleanedParams       []CleanedParam    `json:"cleanedParams,omitempty"`
+	HTTPStatusCode      int               `json:"httpStatusCode"`
+	HTTPRedirectCount   int               `json:"httpRedirectCount"`
+	RedirectChain       []*url.URL        `json:"redirectChain,omitempty"`
+	RedirectHops        []HTTPRedirectHop `json:"redirectHops,omitempty"`
+	TLSInfo             *TLSInfo          `json:"tlsInfo,omitempty"`
+	ContentTypeSniffed  bool              `json:"contentTypeSniffed,omitempty"`
+	DeclaredLength      int64             `json:"declaredLength"`
+	ResolvedURL         *url.URL          `json:"resolvedURL"`
+	CleanedURL          *url.URL          `json:"cleanedURL"`
+	FinalizedURL        *url.URL          `json:"finalizedURL"`
+	Content             resource.Content  `json:"content"`
+	Issues              []Issue           `json:"issues,omitempty"`
+
+	dispositionType string
+}
+
+// CleanedParam records a query param which was removed from the resolved URL and the cleaning rule's reason
+type CleanedParam struct {
+	ParamName string `json:"paramName"`
+	Reason    string `json:"reason"`
+}
+
+// HTTPRedirectHop records the response of a single HTTP redirect, for a forensic view of shortener and tracking
+// chains; hops are only recorded when DefaultFactory.RecordRedirectHops is set
+type HTTPRedirectHop struct {
+	URL        string   `json:"url"`
+	StatusCode int      `json:"statusCode"`
+	Location   string   `json:"location"`
+	SetCookies []string `json:"setCookies,omitempty"`
+}
+
+// Issue is a non-fatal observation recorded while traversing a link, such as a suspicious cleaning result
+type Issue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// String returns a concise one-line summary of the link, suitable for logs and test failures
+func (l *TraversedLink) String() string {
+	finalURL := "<nil>"
+	if l.FinalizedURL != nil {
+		finalURL = l.FinalizedURL.String()
+	}
+	return fmt.Sprintf("%s -> %s (valid: %t, ignored: %t, status: %d)", l.OrigURLText, finalURL, l.IsURLValid, l.IsURLIgnored, l.HTTPStatusCode)
 }
 
 // OriginalURL returns the URL text that was parsed
@@ -38,26 +97,265 @@ func (l *TraversedLink) Ignore() (bool, string) {
 	return l.IsURLIgnored, l.IgnoreReason
 }
 
+// IsHTML returns true if the link's content was HTML which was inspected for metadata
+func (l *TraversedLink) IsHTML() bool {
+	return l.Content != nil && l.Content.Type() != nil && l.Content.IsHTML()
+}
+
+// MediaTypeParam returns the named parameter of the link's Content-Type (e.g. "charset"); names are case-insensitive.
+// It returns false when no content was retrieved, the server didn't send a Content-Type or it lacks the parameter.
+func (l *TraversedLink) MediaTypeParam(name string) (string, bool) {
+	if l.Content == nil || l.Content.Type() == nil {
+		return "", false
+	}
+	value, ok := l.Content.Type().MediaTypeParams()[strings.ToLower(name)]
+	return value, ok
+}
+
+// Charset returns the charset declared in the link's Content-Type header, or an empty string when it's unknown.
+// resource doesn't retain <meta charset> declarations so the HTML itself isn't consulted.
+func (l *TraversedLink) Charset() string {
+	charset, _ := l.MediaTypeParam("charset")
+	return charset
+}
+
+// DispositionType returns the type of the final response's Content-Disposition header, lower-cased ("inline",
+// "attachment" or an extension type), or an empty string when the server didn't send one. It's informational:
+// whether content is parsed or downloaded is decided by resource from the media type alone, so a page served with
+// an attachment disposition is still inspected as HTML.
+func (l *TraversedLink) DispositionType() string {
+	return l.dispositionType
+}
+
+// WasDownloaded returns true if the link's content was downloaded as an attachment instead of being inspected as HTML
+func (l *TraversedLink) WasDownloaded() bool {
+	return l.Content != nil && l.Content.Attachment() != nil
+}
+
+// IsAttachment returns true if the link's content is a file rather than a page to inspect: it was downloaded as
+// an attachment, or its media type isn't HTML (even when no AttachmentsCreator was given to download it). It's
+// false when no content was retrieved or the server didn't send a Content-Type.
+func (l *TraversedLink) IsAttachment() bool {
+	if l.Content == nil {
+		return false
+	}
+	if l.Content.Attachment() != nil {
+		return true
+	}
+	return l.Content.Type() != nil && !l.Content.IsHTML()
+}
+
+// OpenAttachment opens the file downloaded for the link's content so its bytes can be read; the caller must close
+// it. The error wraps ErrNoAttachment when nothing was downloaded (no AttachmentsCreator was given, the content
+// was inspected as HTML or the download failed) and the file system's error when the file is gone, e.g. after Cleanup.
+func (l *TraversedLink) OpenAttachment() (io.ReadCloser, error) {
+	var attachment *resource.FileAttachment
+	if l.Content != nil {
+		attachment, _ = l.Content.Attachment().(*resource.FileAttachment)
+	}
+	if attachment == nil || attachment.DestFS == nil {
+		return nil, xerrors.Errorf("Unable to open attachment of %q: %w", l.OrigURLText, ErrNoAttachment)
+	}
+
+	file, err := attachment.DestFS.Open(attachment.DestPath)
+	if err != nil {
+		return nil, xerrors.Errorf("Unable to open attachment %q of %q: %w", attachment.DestPath, l.OrigURLText, err)
+	}
+	return file, nil
+}
+
+// attachmentDeleter is implemented by attachments which were written to storage and can delete themselves
+type attachmentDeleter interface {
+	Delete()
+}
+
+// Cleanup deletes every downloaded attachment held by the link and the links it was redirected from (see OrigLink).
+// Attachments which can't be deleted are skipped; it's safe to call when nothing was downloaded. If any deletion
+// of a resource.FileAttachment fails, the returned error wraps the first failure (other attachments' Delete
+// doesn't report failures).
+func (l *TraversedLink) Cleanup() error {
+	var firstErr error
+	failed, total := 0, 0
+	for link := l; link != nil; link = link.OrigLink {
+		if link.Content == nil {
+			continue
+		}
+		switch attachment := link.Content.Attachment().(type) {
+		case *resource.FileAttachment:
+			// FileAttachment.Delete discards the error so the file is removed directly
+			if attachment.DestFS == nil {
+				continue
+			}
+			total++
+			if err := attachment.DestFS.Remove(attachment.DestPath); err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		case attachmentDeleter:
+			total++
+			attachment.Delete()
+		}
+	}
+
+	if firstErr != nil {
+		return xerrors.Errorf("Unable to delete %d of %d attachments of %q: %w", failed, total, l.OrigURLText, firstErr)
+	}
+	return nil
+}
+
+// WasShortened returns true if the link redirected to a different registrable domain than the original URL (e.g. t.co or bit.ly)
+func (l *TraversedLink) WasShortened() bool {
+	return len(l.ShortenerHost()) > 0
+}
+
+// ShortenerHost returns the hostname of the first requested URL (the unwrapped URL, if UnwrapURLsRules applied) if
+// the link was shortened, or an empty string otherwise; a link is only considered shortened if it was redirected
+// (through HTTP or, see OrigLink, HTML redirects)
+func (l *TraversedLink) ShortenerHost() string {
+	if l.FinalizedURL == nil {
+		return ""
+	}
+
+	redirected := false
+	orig := l
+	for {
+		if orig.HTTPRedirectCount > 0 || len(orig.RedirectChain) > 0 {
+			redirected = true
+		}
+		if orig.OrigLink == nil {
+			break
+		}
+		redirected = true
+		orig = orig.OrigLink
+	}
+	if !redirected {
+		return ""
+	}
+
+	origURL, err := url.Parse(orig.requestedURLText())
+	if err != nil || len(origURL.Hostname()) == 0 {
+		return ""
+	}
+
+	if GetRegistrableDomain(origURL) != GetRegistrableDomain(l.FinalizedURL) {
+		return origURL.Hostname()
+	}
+	return ""
+}
+
+// requestedURLText returns the URL text which was actually requested: the unwrapped URL if UnwrapURLsRules applied
+func (l *TraversedLink) requestedURLText() string {
+	if len(l.UnwrappedURLText) > 0 {
+		return l.UnwrappedURLText
+	}
+	return l.OrigURLText
+}
+
+// Redirect returns true and the absolute destination if a redirect was requested through <meta http-equiv='refresh' Content='delay;url='>.
+// Relative destinations are resolved against the link's resolved URL. For an explanation, please see
+// http://redirectdetective.com/redirection-types.html
+func (l *TraversedLink) Redirect() (bool, *url.URL) {
+	if l.Content == nil {
+		return false, nil
+	}
+
+	isRedirect, redirectURLText := l.Content.Redirect()
+	if !isRedirect {
+		return false, nil
+	}
+
+	redirectURL, err := url.Parse(redirectURLText)
+	if err != nil {
+		return false, nil
+	}
+	if l.ResolvedURL != nil {
+		redirectURL = l.ResolvedURL.ResolveReference(redirectURL)
+	}
+	return true, redirectURL
+}
+
+// RedirectKind distinguishes how a redirect hop was requested
+type RedirectKind int
+
+const (
+	// HTTPRedirect is a 3xx HTTP response with a Location header
+	HTTPRedirect RedirectKind = iota
+
+	// HTMLRedirect is a <meta http-equiv='refresh'> found in HTML content
+	HTMLRedirect
+)
+
+// String returns a readable name for the redirect kind
+func (k RedirectKind) String() string {
+	switch k {
+	case HTTPRedirect:
+		return "http"
+	case HTMLRedirect:
+		return "html"
+	default:
+		return fmt.Sprintf("RedirectKind(%d)", int(k))
+	}
+}
+
+// WalkRedirects calls fn for each redirect hop, in the order the hops were followed, starting from the original
+// link in the OrigLink chain. HTTP hops come from each link's RedirectChain and HTML (meta refresh) hops connect
+// a link to the one it redirected to. Hops are numbered from 1; fn is never called when there were no redirects.
+func (l *TraversedLink) WalkRedirects(fn func(hop int, from, to *url.URL, kind RedirectKind)) {
+	var links []*TraversedLink
+	for link := l; link != nil; link = link.OrigLink {
+		links = append([]*TraversedLink{link}, links...)
+	}
+
+	hop := 0
+	for i, link := range links {
+		for j := 1; j < len(link.RedirectChain); j++ {
+			hop++
+			fn(hop, link.RedirectChain[j-1], link.RedirectChain[j], HTTPRedirect)
+		}
+
+		if i+1 < len(links) {
+			to, err := url.Parse(links[i+1].OrigURLText)
+			if err != nil {
+				continue
+			}
+			hop++
+			fn(hop, link.ResolvedURL, to, HTMLRedirect)
+		}
+	}
+}
+
 // IsHTMLRedirect returns true if redirect was requested through via <meta http-equiv='refresh' Content='delay;url='>
-// For an explanation, please see http://redirectdetective.com/redirection-types.html
+//
+// Deprecated: use Redirect, which returns the parsed and resolved destination URL.
 func (l *TraversedLink) IsHTMLRedirect() (bool, string) {
-	if l.Content != nil {
-		return l.Content.Redirect()
+	isRedirect, redirectURL := l.Redirect()
+	if !isRedirect {
+		return false, ""
 	}
-	return false, ""
+	return true, redirectURL.String()
 }
 
-// Traversable returns true if this link is traversable or has been traversed
+// Traversable returns true if this link is traversable or has been traversed. The reason an invalid or ignored
+// link isn't traversable is reported through warn first, followed by any Issues recorded on the link.
 func (l *TraversedLink) Traversable(warn func(code, message string)) bool {
+	traversable := true
 	if !l.IsURLValid {
-		warn("LECTIOLINK-001-INVALIDURL", l.IgnoreReason)
-		return false
+		code := l.InvalidURLCode
+		if len(code) == 0 {
+			code = "LECTIOLINK-001-INVALIDURL"
+		}
+		warn(code, l.IgnoreReason)
+		traversable = false
+	} else if l.IsURLIgnored {
+		warn("LECTIOLINK-002-URLIGNORED", l.IgnoreReason)
+		traversable = false
 	}
 
-	if l.IsURLIgnored {
-		warn("LECTIOLINK-002-URLIGNORED", l.IgnoreReason)
-		return false
+	for _, issue := range l.Issues {
+		warn(issue.Code, issue.Message)
 	}
 
-	return true
+	return traversable
 }